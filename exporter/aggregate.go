@@ -0,0 +1,134 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+// Servers aggregation of a master server
+type Aggregation struct {
+	// Aggregation key of a server
+	Key func(server *server.Server) string
+	// Value of a server, summed per key
+	Value func(server *server.Server) float64
+}
+
+// Get the Prometheus labels of an aggregated metric
+func aggregateLabels(label string) []string {
+	return append([]string{label}, MasterServerLabels...)
+}
+
+// Count the players of a server
+func serverPlayers(server *server.Server) float64 {
+	return float64(len(server.Info.Clients))
+}
+
+// Count a server
+func serverCount(server *server.Server) float64 {
+	return 1
+}
+
+// Get the location of a server, only known from the DDNet HTTP master servers
+func serverLocation(server *server.Server) string {
+	if server.Location == "" {
+		return "unknown"
+	}
+
+	return server.Location
+}
+
+var (
+	// Teeworlds servers aggregated metrics informations associated with the aggregation
+	AggregateMetrics = map[*MetricInfo]Aggregation{
+		{
+			Desc: prometheus.NewDesc("teeworlds_gametype_players", "Total number of players per game type on a master server.", aggregateLabels("gametype"), nil),
+			Type: prometheus.GaugeValue,
+		}: {
+			Key: func(server *server.Server) string {
+				return server.Info.GameType
+			},
+			Value: serverPlayers,
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_gametype_servers", "Total number of servers per game type on a master server.", aggregateLabels("gametype"), nil),
+			Type: prometheus.GaugeValue,
+		}: {
+			Key: func(server *server.Server) string {
+				return server.Info.GameType
+			},
+			Value: serverCount,
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_map_players", "Total number of players per map on a master server.", aggregateLabels("map"), nil),
+			Type: prometheus.GaugeValue,
+		}: {
+			Key: func(server *server.Server) string {
+				return server.Info.Map.Name
+			},
+			Value: serverPlayers,
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_version_servers", "Total number of servers per version on a master server.", aggregateLabels("version"), nil),
+			Type: prometheus.GaugeValue,
+		}: {
+			Key: func(server *server.Server) string {
+				return server.Info.Version
+			},
+			Value: serverCount,
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_location_players", "Total number of players per location on a master server.", aggregateLabels("location"), nil),
+			Type: prometheus.GaugeValue,
+		}: {
+			Key:   serverLocation,
+			Value: serverPlayers,
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_location_servers", "Total number of servers per location on a master server.", aggregateLabels("location"), nil),
+			Type: prometheus.GaugeValue,
+		}: {
+			Key:   serverLocation,
+			Value: serverCount,
+		},
+	}
+)
+
+// Send Teeworlds servers aggregated Prometheus metric, per master server
+func SendAggregateMetrics(
+	metricInfo *MetricInfo,
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+	aggregation Aggregation,
+) error {
+	if metricInfo == nil {
+		return fmt.Errorf("missing metric info")
+	}
+
+	for _, snapshot := range snapshots {
+		values := make(map[string]float64)
+
+		for _, server := range snapshot.Servers {
+			if server == nil {
+				continue
+			}
+
+			values[aggregation.Key(server)] += aggregation.Value(server)
+		}
+
+		for key, value := range values {
+			ch <- prometheus.MustNewConstMetric(
+				metricInfo.Desc,
+				metricInfo.Type,
+				value,
+				key,
+				snapshot.Metadata.Address,
+				snapshot.Metadata.Protocol,
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+func TestSendAggregateMetrics(t *testing.T) {
+	metricInfo := &MetricInfo{
+		Desc: prometheus.NewDesc("test_location_players", "", aggregateLabels("location"), nil),
+		Type: prometheus.GaugeValue,
+	}
+
+	eu := newServer("1.1.1.1:8303", 3)
+	eu.Location = "eu:de"
+
+	snapshots := []masterserver.MasterServerSnapshot{
+		{
+			Metadata: masterserver.MasterServerMetadata{Protocol: "http", Address: "master"},
+			Servers:  []*server.Server{eu, eu, newServer("2.2.2.2:8303", 2)},
+		},
+	}
+
+	ch := make(chan prometheus.Metric, 16)
+
+	err := SendAggregateMetrics(metricInfo, snapshots, ch, Aggregation{
+		Key:   serverLocation,
+		Value: serverPlayers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(ch)
+
+	got := make(map[string]float64)
+
+	for metric := range ch {
+		m := &dto.Metric{}
+		_ = metric.Write(m)
+
+		got[m.GetLabel()[1].GetValue()] = m.GetGauge().GetValue()
+	}
+
+	expected := map[string]float64{"eu:de": 6, "unknown": 2}
+
+	for location, value := range expected {
+		if got[location] != value {
+			t.Errorf("%s: expected %v players, got %v", location, value, got[location])
+		}
+	}
+}
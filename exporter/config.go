@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/config"
+)
+
+var (
+	// Configuration reload success Prometheus metric
+	ConfigReloadSuccessMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_exporter_config_last_reload_successful", "Whether the last configuration reload attempt was successful.", nil, nil),
+		Type: prometheus.GaugeValue,
+	}
+
+	// Configuration reload timestamp Prometheus metric
+	ConfigReloadTimestampMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_exporter_config_last_reload_success_timestamp_seconds", "Timestamp of the last successful configuration reload.", nil, nil),
+		Type: prometheus.GaugeValue,
+	}
+)
+
+// Send the configuration reload Prometheus metrics
+func SendConfigMetrics(status config.ReloadStatus, ch chan<- prometheus.Metric) error {
+	successful := 0.0
+
+	if status.Successful {
+		successful = 1.0
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		ConfigReloadSuccessMetric.Desc,
+		ConfigReloadSuccessMetric.Type,
+		successful,
+	)
+
+	timestamp := 0.0
+
+	if !status.LastSuccess.IsZero() {
+		timestamp = float64(status.LastSuccess.UnixNano()) / 1e9
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		ConfigReloadTimestampMetric.Desc,
+		ConfigReloadTimestampMetric.Type,
+		timestamp,
+	)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+)
+
+var (
+	// Players per country Prometheus metric
+	PlayersByCountryMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_players_by_country", "Number of players per ISO 3166-1 alpha-2 country code, across the master servers.", []string{"country"}, nil),
+		Type: prometheus.GaugeValue,
+	}
+
+	// Players per clan Prometheus metric
+	PlayersByClanMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_players_by_clan", "Number of players of the clans with the most players, across the master servers.", []string{"clan"}, nil),
+		Type: prometheus.GaugeValue,
+	}
+)
+
+// Send the players distribution Prometheus metrics, the servers listed by
+// several master servers are counted once. Only the `topClans` clans with
+// the most players are sent.
+func SendPlayerDistributionMetrics(
+	mergedServers []*masterservers.MergedServer,
+	topClans uint,
+	ch chan<- prometheus.Metric,
+) error {
+	countries := make(map[string]uint)
+	clans := make(map[string]uint)
+
+	for _, mergedServer := range mergedServers {
+		for i := range mergedServer.Server.Info.Clients {
+			client := &mergedServer.Server.Info.Clients[i]
+
+			countries[client.CountryCode()]++
+
+			// Label values must be valid UTF-8, the UDP and
+			// the game servers send the clans as they are
+			if client.Clan != "" {
+				clans[strings.ToValidUTF8(client.Clan, "�")]++
+			}
+		}
+	}
+
+	for country, players := range countries {
+		ch <- prometheus.MustNewConstMetric(
+			PlayersByCountryMetric.Desc,
+			PlayersByCountryMetric.Type,
+			float64(players),
+			country,
+		)
+	}
+
+	if topClans == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(clans))
+	for clan := range clans {
+		names = append(names, clan)
+	}
+
+	// Most players first, then by name so the kept clans are stable
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(clans[b], clans[a]), cmp.Compare(a, b))
+	})
+
+	if uint(len(names)) > topClans {
+		names = names[:topClans]
+	}
+
+	for _, clan := range names {
+		ch <- prometheus.MustNewConstMetric(
+			PlayersByClanMetric.Desc,
+			PlayersByClanMetric.Type,
+			float64(clans[clan]),
+			clan,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	twclient "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/client"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+)
+
+func TestSendPlayerDistributionMetrics(t *testing.T) {
+	s := newServer("1.1.1.1:8303", 0)
+	s.Info.Clients = []twclient.Client{
+		{Clan: "a", Country: 276},
+		{Clan: "a", Country: 276},
+		{Clan: "b", Country: 250},
+		{Clan: "c", Country: -1},
+		{Clan: "c", Country: -1},
+		{Country: -1},
+	}
+
+	ch := make(chan prometheus.Metric, 16)
+
+	err := SendPlayerDistributionMetrics([]*masterservers.MergedServer{{Server: s}}, 2, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(ch)
+
+	countries := make(map[string]float64)
+	clans := make(map[string]float64)
+
+	for metric := range ch {
+		m := &dto.Metric{}
+		_ = metric.Write(m)
+
+		label := m.GetLabel()[0]
+
+		if label.GetName() == "country" {
+			countries[label.GetValue()] = m.GetGauge().GetValue()
+		} else {
+			clans[label.GetValue()] = m.GetGauge().GetValue()
+		}
+	}
+
+	expectedCountries := map[string]float64{"DE": 2, "FR": 1, twclient.CountryUnknown: 3}
+	for country, players := range expectedCountries {
+		if countries[country] != players {
+			t.Errorf("%s: expected %v players, got %v", country, players, countries[country])
+		}
+	}
+
+	// The top 2 clans, ties broken by name
+	if len(clans) != 2 || clans["a"] != 2 || clans["c"] != 2 {
+		t.Errorf("unexpected clans %v", clans)
+	}
+}
+
+func TestSendPlayerDistributionMetricsInvalidClan(t *testing.T) {
+	s := newServer("1.1.1.1:8303", 0)
+	s.Info.Clients = []twclient.Client{
+		// A clan truncated in the middle of a multibyte character
+		{Clan: string([]byte("clan \xc3")), Country: -1},
+	}
+
+	ch := make(chan prometheus.Metric, 16)
+
+	err := SendPlayerDistributionMetrics([]*masterservers.MergedServer{{Server: s}}, 1, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(ch)
+
+	for metric := range ch {
+		m := &dto.Metric{}
+		if err := metric.Write(m); err != nil {
+			t.Error(err)
+		}
+	}
+}
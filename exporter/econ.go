@@ -20,6 +20,63 @@ var (
 		Desc: prometheus.NewDesc("teeworlds_econ_event_total", "Total number of received econ events.", EconLabels, nil),
 		Type: prometheus.CounterValue,
 	}
+
+	// Econ connection state Prometheus labels
+	EconStateLabels = []string{
+		"address",
+		"port",
+	}
+
+	// Econ connection state metrics informations associated with function to scrape a metric
+	EconStateMetrics = map[*MetricInfo]func(state econ.EconState) float64{
+		{
+			Desc: prometheus.NewDesc("teeworlds_econ_up", "Whether the econ client is connected and authenticated.", EconStateLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(state econ.EconState) float64 {
+			if state.Up {
+				return 1
+			}
+
+			return 0
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_econ_reconnects_total", "Total number of econ reconnections.", EconStateLabels, nil),
+			Type: prometheus.CounterValue,
+		}: func(state econ.EconState) float64 {
+			return float64(state.Reconnects)
+		},
+	}
+)
+
+var (
+	// Econ player statistics Prometheus labels
+	EconPlayerLabels = []string{
+		"address",
+		"port",
+		"player",
+	}
+
+	// Econ player statistics metrics informations associated with function to scrape a metric
+	EconPlayerMetrics = map[*MetricInfo]func(stats econ.PlayerStats) float64{
+		{
+			Desc: prometheus.NewDesc("teeworlds_econ_player_kills_total", "Total number of kills of a player.", EconPlayerLabels, nil),
+			Type: prometheus.CounterValue,
+		}: func(stats econ.PlayerStats) float64 {
+			return float64(stats.Kills)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_econ_player_deaths_total", "Total number of deaths of a player.", EconPlayerLabels, nil),
+			Type: prometheus.CounterValue,
+		}: func(stats econ.PlayerStats) float64 {
+			return float64(stats.Deaths)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_econ_player_flag_captures_total", "Total number of flag captures of a player.", EconPlayerLabels, nil),
+			Type: prometheus.CounterValue,
+		}: func(stats econ.PlayerStats) float64 {
+			return float64(stats.FlagCaptures)
+		},
+	}
 )
 
 // Send Teeworlds econ servers Prometheus metric
@@ -45,3 +102,50 @@ func SendEconServerMetrics(
 
 	return nil
 }
+
+// Send Teeworlds econ servers connection state Prometheus metrics
+func SendEconStateMetrics(
+	metadata econ.EconMananagerKey,
+	state econ.EconState,
+	ch chan<- prometheus.Metric,
+) error {
+	labelValues := []string{
+		metadata.Host,
+		fmt.Sprintf("%d", metadata.Port),
+	}
+
+	for metricInfo, f := range EconStateMetrics {
+		ch <- prometheus.MustNewConstMetric(
+			metricInfo.Desc,
+			metricInfo.Type,
+			f(state),
+			labelValues...,
+		)
+	}
+
+	return nil
+}
+
+// Send Teeworlds econ servers player statistics Prometheus metrics
+func SendEconPlayerMetrics(
+	metadata econ.EconMananagerKey,
+	players map[string]econ.PlayerStats,
+	ch chan<- prometheus.Metric,
+) error {
+	port := fmt.Sprintf("%d", metadata.Port)
+
+	for name, stats := range players {
+		for metricInfo, f := range EconPlayerMetrics {
+			ch <- prometheus.MustNewConstMetric(
+				metricInfo.Desc,
+				metricInfo.Type,
+				f(stats),
+				metadata.Host,
+				port,
+				name,
+			)
+		}
+	}
+
+	return nil
+}
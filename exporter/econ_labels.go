@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
+	econ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+)
+
+var (
+	// Econ labeled events dropped Prometheus metric
+	EconLabeledDroppedMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_econ_event_label_sets_dropped_total", "Total number of econ events not counted in their labeled metric because of the label sets limit.", EconLabels, nil),
+		Type: prometheus.CounterValue,
+	}
+)
+
+// Prometheus collector for the econ events labeled by their regex named
+// capture groups. Their metric names and labels depend on the configuration,
+// so it is an unchecked collector, it does not describe any metric.
+type EconLabelsExporter struct {
+	// Teeworlds econ servers manager
+	em *econ.EconManager
+}
+
+// Create a new econ labeled events exporter
+func NewEconLabelsExporter(em *econ.EconManager) *EconLabelsExporter {
+	return &EconLabelsExporter{
+		em: em,
+	}
+}
+
+// Get the Prometheus description of an event labeled metric
+func econLabeledDesc(event string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		fmt.Sprintf("teeworlds_econ_event_%s_total", event),
+		fmt.Sprintf("Total number of received %s econ events by named capture group.", event),
+		append(append([]string{}, EconStateLabels...), labels...),
+		nil,
+	)
+}
+
+// Send Teeworlds econ servers labeled events Prometheus metrics,
+// the label sets that cannot be exported are skipped
+func SendEconLabeledMetrics(
+	metadata econ.EconMananagerKey,
+	labeledMetrics map[string]econ.EconLabeledMetrics,
+	ch chan<- prometheus.Metric,
+) error {
+	var lastErr error
+
+	port := fmt.Sprintf("%d", metadata.Port)
+
+	for event, m := range labeledMetrics {
+		desc := econLabeledDesc(event, m.Labels)
+
+		for _, set := range m.LabelSets {
+			labelValues := append([]string{metadata.Host, port}, set.Values...)
+
+			metric, err := prometheus.NewConstMetric(
+				desc,
+				prometheus.CounterValue,
+				float64(set.Count),
+				labelValues...,
+			)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			ch <- metric
+		}
+
+		metric, err := prometheus.NewConstMetric(
+			EconLabeledDroppedMetric.Desc,
+			EconLabeledDroppedMetric.Type,
+			float64(m.Dropped),
+			metadata.Host,
+			port,
+			event,
+		)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		ch <- metric
+	}
+
+	return lastErr
+}
+
+// Unchecked collector, nothing is described
+func (e *EconLabelsExporter) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect the econ labeled events metrics
+func (e *EconLabelsExporter) Collect(ch chan<- prometheus.Metric) {
+	for metadata, labeledMetrics := range e.em.EconServersLabeledMetrics() {
+		if err := SendEconLabeledMetrics(metadata, labeledMetrics, ch); err != nil {
+			debug.Debug(err.Error())
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package exporter
 
 import (
+	"reflect"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/config"
 	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
 	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
 	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
 )
 
 // Prometheus exporter collector
@@ -14,38 +19,190 @@ type Exporter struct {
 	msm *masterservers.MasterServerManager
 	// Teeworlds econ servers manager
 	em *econ.EconManager
+	// Configuration reloader
+	reloader *config.Reloader
+	// Whether the servers listed by several master servers are emitted once
+	mergeServers bool
+	// Server metrics view and the configuration it has been built from
+	view       *ServerMetricsView
+	viewConfig config.ServerMetrics
+	// Master server refresh duration histogram and the configuration
+	// it has been built from
+	requestDuration       *prometheus.HistogramVec
+	requestDurationConfig config.RequestDuration
+	// Mutex protecting the server metrics view and the histogram
+	mu sync.Mutex
 }
 
 // Create a new exporter struct
-func NewExporter(msm *masterservers.MasterServerManager, em *econ.EconManager) *Exporter {
+func NewExporter(
+	msm *masterservers.MasterServerManager,
+	em *econ.EconManager,
+	reloader *config.Reloader,
+) *Exporter {
 	return &Exporter{
-		msm: msm,
-		em:  em,
+		msm:      msm,
+		em:       em,
+		reloader: reloader,
+	}
+}
+
+// Emit the servers listed by several master servers once,
+// with the number of master servers listing them
+func (e *Exporter) SetMergeServers(mergeServers bool) {
+	e.mergeServers = mergeServers
+}
+
+// Get the server metrics view of the running configuration,
+// it is only rebuilt when the configuration changes
+func (e *Exporter) serverMetricsView() *ServerMetricsView {
+	if e.reloader == nil {
+		return DefaultServerMetricsView
+	}
+
+	c := e.reloader.ServerMetrics()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.view == nil || !reflect.DeepEqual(c, e.viewConfig) {
+		e.view = NewServerMetricsView(c)
+		e.viewConfig = c
 	}
+
+	return e.view
+}
+
+// Get the refresh duration histogram of the running configuration,
+// it is only rebuilt when the configuration changes, `e.mu` must be held
+func (e *Exporter) requestDurationHistogram() *prometheus.HistogramVec {
+	var c config.RequestDuration
+
+	if e.reloader != nil {
+		c = e.reloader.RequestDuration()
+	}
+
+	if e.requestDuration == nil || !reflect.DeepEqual(c, e.requestDurationConfig) {
+		e.requestDuration = NewRequestDurationHistogram(c)
+		e.requestDurationConfig = c
+	}
+
+	return e.requestDuration
+}
+
+// Record a master server refresh duration, it is meant
+// to be the refresh observer of the master servers manager
+func (e *Exporter) ObserveRefresh(
+	metadata masterserver.MasterServerMetadata,
+	phase string,
+	seconds float64,
+) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requestDurationHistogram().
+		WithLabelValues(metadata.Address, metadata.Protocol, phase).
+		Observe(seconds)
+}
+
+// Delete the refresh duration series of a master server, it is meant
+// to be the removal observer of the master servers manager
+func (e *Exporter) ForgetMasterServer(metadata masterserver.MasterServerMetadata) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.requestDuration == nil {
+		return
+	}
+
+	e.requestDuration.DeletePartialMatch(prometheus.Labels{
+		MasterServerLabels[0]: metadata.Address,
+		MasterServerLabels[1]: metadata.Protocol,
+	})
+}
+
+// Collect the master server refresh duration histogram
+func (e *Exporter) collectRequestDuration(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	histogram := e.requestDurationHistogram()
+	e.mu.Unlock()
+
+	histogram.Collect(ch)
 }
 
 // Collect the Teeworlds servers metrics
-func (e *Exporter) collectServers(ch chan<- prometheus.Metric) {
-	for metricInfo, f := range ServerMetrics {
-		err := SendServerMetrics(metricInfo, e.msm, ch, f)
+func (e *Exporter) collectServers(
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+) {
+	entries := ServerEntries(snapshots, e.mergeServers)
+	dropped := e.serverMetricsView().Send(entries, ch)
+
+	ch <- prometheus.MustNewConstMetric(
+		ServersDroppedMetric.Desc,
+		ServersDroppedMetric.Type,
+		float64(dropped),
+	)
+}
+
+// Collect the Teeworlds master servers metrics
+func (e *Exporter) collectMasterServers(
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+) {
+	for metricInfo, f := range MasterServerMetrics {
+		err := SendMasterServerMetrics(metricInfo, snapshots, ch, f)
 		if err != nil {
 			debug.Debug(err.Error())
 		}
 	}
-}
 
-// Collect the Teeworlds master servers metrics
-func (e *Exporter) collectMasterServers(ch chan<- prometheus.Metric) {
-	masterServers := e.msm.MasterServers()
+	if err := SendMasterServerSourceMetrics(snapshots, ch); err != nil {
+		debug.Debug(err.Error())
+	}
 
-	for metricInfo, f := range MasterServerMetrics {
-		err := SendMasterServerMetrics(metricInfo, masterServers, ch, f)
+	for metricInfo, f := range MasterServerMirrorMetrics {
+		err := SendMasterServerMirrorMetrics(metricInfo, snapshots, ch, f)
+		if err != nil {
+			debug.Debug(err.Error())
+		}
+	}
+}
+
+// Collect the Teeworlds servers aggregated metrics
+func (e *Exporter) collectAggregates(
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+) {
+	for metricInfo, aggregation := range AggregateMetrics {
+		err := SendAggregateMetrics(metricInfo, snapshots, ch, aggregation)
 		if err != nil {
 			debug.Debug(err.Error())
 		}
 	}
 }
 
+// Collect the players distribution metrics
+func (e *Exporter) collectPlayerDistribution(
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+) {
+	var topClans uint
+
+	if e.reloader != nil {
+		topClans = e.reloader.PlayerDistribution().TopClans
+	}
+
+	err := SendPlayerDistributionMetrics(
+		masterservers.MergeSnapshots(snapshots),
+		topClans,
+		ch,
+	)
+	if err != nil {
+		debug.Debug(err.Error())
+	}
+}
+
 // Collect the Teeworlds econ servers metrics
 func (e *Exporter) collectEconServers(ch chan<- prometheus.Metric) {
 	econServersMetrics := e.em.EconServersMetrics()
@@ -56,32 +213,67 @@ func (e *Exporter) collectEconServers(ch chan<- prometheus.Metric) {
 			debug.Debug(err.Error())
 		}
 	}
-}
 
-// Send Prometheus metric description that represents the metrics attributes
-func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	// Teeworlds server metrics
-	for metricInfo := range ServerMetrics {
-		ch <- metricInfo.Desc
+	econServersState := e.em.EconServersState()
+
+	for metadata, state := range econServersState {
+		err := SendEconStateMetrics(metadata, state, ch)
+		if err != nil {
+			debug.Debug(err.Error())
+		}
 	}
 
-	// Teeworlds master server metrics
-	for metricInfo := range MasterServerMetrics {
-		ch <- metricInfo.Desc
+	econServersPlayers := e.em.EconServersPlayers()
+
+	for metadata, players := range econServersPlayers {
+		err := SendEconPlayerMetrics(metadata, players, ch)
+		if err != nil {
+			debug.Debug(err.Error())
+		}
 	}
+}
 
-	// Teeworlds econ server metric
-	ch <- EconMetric.Desc
+// Collect the configuration reload metrics
+func (e *Exporter) collectConfig(ch chan<- prometheus.Metric) {
+	if e.reloader == nil {
+		return
+	}
+
+	err := SendConfigMetrics(e.reloader.Status(), ch)
+	if err != nil {
+		debug.Debug(err.Error())
+	}
 }
 
+// The labels of the Teeworlds servers metrics depend on the configuration,
+// so it is an unchecked collector, it does not describe any metric. A single
+// collector takes the snapshots once, so every metric of a scrape derives
+// from the same refreshes.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {}
+
 // Collect implements required collect function for all promehteus exporters
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	// One snapshot per master server, shared by every metric of the scrape
+	snapshots := e.msm.Snapshots()
+
 	// Teeworlds servers
-	e.collectServers(ch)
+	e.collectServers(snapshots, ch)
 
 	// Teeworlds master servers
-	e.collectMasterServers(ch)
+	e.collectMasterServers(snapshots, ch)
+
+	// Teeworlds master servers refresh durations
+	e.collectRequestDuration(ch)
+
+	// Teeworlds servers aggregated per master server
+	e.collectAggregates(snapshots, ch)
+
+	// Players distribution across the master servers
+	e.collectPlayerDistribution(snapshots, ch)
 
 	// Teeworlds econ servers
 	e.collectEconServers(ch)
+
+	// Configuration reload
+	e.collectConfig(ch)
 }
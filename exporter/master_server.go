@@ -14,90 +14,161 @@ var (
 		"protocol",
 	}
 
+	// Master server mirror Prometheus labels
+	MasterServerMirrorLabels = []string{
+		"protocol",
+		"url",
+	}
+
 	// Teeworlds master server metrics informations associated with function to scrape a metric
-	MasterServerMetrics = map[*MetricInfo]func(masterServer *masterserver.MasterServer) float64{
+	MasterServerMetrics = map[*MetricInfo]func(snapshot *masterserver.MasterServerSnapshot) float64{
 		{
 			Desc: prometheus.NewDesc("teeworlds_master_server_players", "Total number of players on a master server.", MasterServerLabels, nil),
 			Type: prometheus.GaugeValue,
-		}: func(masterServer *masterserver.MasterServer) float64 {
-			s := 0
-
-			// Assuming masterServer cannot be nil
-			servers, _ := (*masterServer).Servers()
-
-			for _, server := range servers {
-				if server == nil {
-					continue
-				}
-
-				s += len(server.Info.Clients)
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.TotalPlayers)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_servers", "Total number of servers registered on a master server.", MasterServerLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.TotalServers)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_servers_filtered", "Number of servers of a master server removed by the filters.", MasterServerLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.Filtered)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_last_request_duration_seconds", "Duration of the last successful master server refresh. From client request to full data server response.", MasterServerLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return snapshot.Metrics.RequestTime
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_last_success_timestamp_seconds", "Timestamp of the last successful master server refresh, zero if none succeeded.", MasterServerLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			if snapshot.Metrics.LastSuccess.IsZero() {
+				return 0
 			}
 
-			return float64(s)
+			return float64(snapshot.Metrics.LastSuccess.UnixNano()) / 1e9
 		},
 		{
-			Desc: prometheus.NewDesc("teeworlds_master_server_servers", "Total number of servers registered on a master server.", MasterServerLabels, nil),
+			Desc: prometheus.NewDesc("teeworlds_master_server_response_bytes", "Number of body bytes received by the last successful master server refresh, before decompression.", MasterServerLabels, nil),
 			Type: prometheus.GaugeValue,
-		}: func(masterServer *masterserver.MasterServer) float64 {
-			// Assuming masterServer cannot be nil
-			servers, _ := (*masterServer).Servers()
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.Metrics.ResponseBytes)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_not_modified_total", "Total number of master server refreshes answered with unchanged servers.", MasterServerLabels, nil),
+			Type: prometheus.CounterValue,
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.Metrics.NotModifiedCount)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_stale", "Whether the master server servers are not exported because its last successful refresh is older than stale_after.", MasterServerLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			if snapshot.Stale {
+				return 1
+			}
 
-			return float64(len(servers))
+			return 0
 		},
 		{
-			Desc: prometheus.NewDesc("teeworlds_master_server_request_duration_seconds", "Request duration when refreshing a master server. From client request to full data server response.", MasterServerLabels, nil),
+			Desc: prometheus.NewDesc("teeworlds_master_server_up", "Whether the last master server refresh succeeded.", MasterServerLabels, nil),
 			Type: prometheus.GaugeValue,
-		}: func(masterServer *masterserver.MasterServer) float64 {
-			// Assuming masterServer cannot be nil
-			metrics := (*masterServer).Metrics()
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			if snapshot.Metrics.Up {
+				return 1
+			}
 
-			return float64(metrics.RequestTime)
+			return 0
 		},
 		{
 			Desc: prometheus.NewDesc("teeworlds_master_server_request_total", "Total number of master server requests.", MasterServerLabels, prometheus.Labels{"state": "failed"}),
 			Type: prometheus.CounterValue,
-		}: func(masterServer *masterserver.MasterServer) float64 {
-			// Assuming masterServer cannot be nil
-			metrics := (*masterServer).Metrics()
-
-			return float64(metrics.FailedRefreshCount)
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.Metrics.FailedRefreshCount)
 		},
 		{
 			Desc: prometheus.NewDesc("teeworlds_master_server_request_total", "Total number of master server requests.", MasterServerLabels, prometheus.Labels{"state": "success"}),
 			Type: prometheus.CounterValue,
-		}: func(masterServer *masterserver.MasterServer) float64 {
-			// Assuming masterServer cannot be nil
-			metrics := (*masterServer).Metrics()
+		}: func(snapshot *masterserver.MasterServerSnapshot) float64 {
+			return float64(snapshot.Metrics.SuccessRefreshCount)
+		},
+	}
+)
+
+var (
+	// Teeworlds master server mirrors metrics informations associated with function to scrape a metric
+	MasterServerMirrorMetrics = map[*MetricInfo]func(mirror *masterserver.MirrorHealth) float64{
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_mirror_up", "Whether the last request to a master server mirror url succeeded.", MasterServerMirrorLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(mirror *masterserver.MirrorHealth) float64 {
+			if mirror.Up {
+				return 1
+			}
 
-			return float64(metrics.SuccessRefreshCount)
+			return 0
 		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_mirror_last_success_timestamp_seconds", "Timestamp of the last successful request to a master server mirror url, zero if none succeeded.", MasterServerMirrorLabels, nil),
+			Type: prometheus.GaugeValue,
+		}: func(mirror *masterserver.MirrorHealth) float64 {
+			if mirror.LastSuccess.IsZero() {
+				return 0
+			}
+
+			return float64(mirror.LastSuccess.UnixNano()) / 1e9
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_mirror_request_total", "Total number of master server mirror url requests.", MasterServerMirrorLabels, prometheus.Labels{"state": "failed"}),
+			Type: prometheus.CounterValue,
+		}: func(mirror *masterserver.MirrorHealth) float64 {
+			return float64(mirror.FailedRequestCount)
+		},
+		{
+			Desc: prometheus.NewDesc("teeworlds_master_server_mirror_request_total", "Total number of master server mirror url requests.", MasterServerMirrorLabels, prometheus.Labels{"state": "success"}),
+			Type: prometheus.CounterValue,
+		}: func(mirror *masterserver.MirrorHealth) float64 {
+			return float64(mirror.SuccessRequestCount)
+		},
+	}
+)
+
+var (
+	// Url that served the servers of a master server with several ones
+	MasterServerSourceMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_master_server_source_info", "Url that served the servers of a master server with several mirror urls, always 1.", append(append([]string{}, MasterServerLabels...), "url"), nil),
+		Type: prometheus.GaugeValue,
 	}
 )
 
 // Send Teeworlds master servers Prometheus metric
 func SendMasterServerMetrics(
 	metricInfo *MetricInfo,
-	masterServers []*masterserver.MasterServer,
+	snapshots []masterserver.MasterServerSnapshot,
 	ch chan<- prometheus.Metric,
-	f func(*masterserver.MasterServer) float64,
+	f func(*masterserver.MasterServerSnapshot) float64,
 ) error {
 	if metricInfo == nil {
 		return fmt.Errorf("missing metric info")
 	}
 
-	for _, masterServer := range masterServers {
-		if masterServer == nil {
-			continue
-		}
-
-		metadata := (*masterServer).Metadata()
+	for i := range snapshots {
+		snapshot := &snapshots[i]
 
 		labelValues := []string{
-			metadata.Address,
-			metadata.Protocol,
+			snapshot.Metadata.Address,
+			snapshot.Metadata.Protocol,
 		}
 
-		metricValue := f(masterServer)
+		metricValue := f(snapshot)
 
 		ch <- prometheus.MustNewConstMetric(
 			metricInfo.Desc,
@@ -109,3 +180,56 @@ func SendMasterServerMetrics(
 
 	return nil
 }
+
+// Send the url that served the servers of the master servers with several ones
+func SendMasterServerSourceMetrics(
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+) error {
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+
+		if snapshot.Source == "" {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			MasterServerSourceMetric.Desc,
+			MasterServerSourceMetric.Type,
+			1,
+			snapshot.Metadata.Address,
+			snapshot.Metadata.Protocol,
+			snapshot.Source,
+		)
+	}
+
+	return nil
+}
+
+// Send Teeworlds master servers mirrors Prometheus metric
+func SendMasterServerMirrorMetrics(
+	metricInfo *MetricInfo,
+	snapshots []masterserver.MasterServerSnapshot,
+	ch chan<- prometheus.Metric,
+	f func(*masterserver.MirrorHealth) float64,
+) error {
+	if metricInfo == nil {
+		return fmt.Errorf("missing metric info")
+	}
+
+	for i := range snapshots {
+		mirrors := snapshots[i].Metrics.Mirrors
+
+		for j := range mirrors {
+			ch <- prometheus.MustNewConstMetric(
+				metricInfo.Desc,
+				metricInfo.Type,
+				f(&mirrors[j]),
+				snapshots[i].Metadata.Protocol,
+				mirrors[j].URL,
+			)
+		}
+	}
+
+	return nil
+}
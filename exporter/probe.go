@@ -0,0 +1,149 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
+	gameserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/game_server"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+var (
+	// Master server protocol label value used for the probed servers
+	ProbeProtocol = "probe"
+
+	// Probe success Prometheus metric
+	ProbeSuccessMetric = MetricInfo{
+		Desc: prometheus.NewDesc("probe_success", "Whether the Teeworlds server probe succeeded.", nil, nil),
+		Type: prometheus.GaugeValue,
+	}
+
+	// Probe duration Prometheus metric
+	ProbeDurationMetric = MetricInfo{
+		Desc: prometheus.NewDesc("probe_duration_seconds", "Duration of the Teeworlds server probe in seconds.", nil, nil),
+		Type: prometheus.GaugeValue,
+	}
+)
+
+// Prometheus collector for a single probed Teeworlds game server
+type ProbeExporter struct {
+	// Probed server, nil if the probe failed
+	server *server.Server
+	// Probed server address
+	target string
+	// Probe duration
+	duration time.Duration
+}
+
+// Probe a Teeworlds game server then create the associated collector
+func NewProbeExporter(
+	ctx context.Context,
+	target string,
+	protocol gameserver.Protocol,
+) *ProbeExporter {
+	start := time.Now()
+
+	s, err := gameserver.ServerInfo(ctx, target, protocol)
+	if err != nil {
+		debug.Debug("could not probe %s with protocol %s: %v", target, protocol, err)
+	}
+
+	return &ProbeExporter{
+		server:   s,
+		target:   target,
+		duration: time.Since(start),
+	}
+}
+
+// Send Prometheus metric description that represents the metrics attributes
+func (e *ProbeExporter) Describe(ch chan<- *prometheus.Desc) {
+	DefaultServerMetricsView.Describe(ch)
+
+	ch <- ProbeSuccessMetric.Desc
+	ch <- ProbeDurationMetric.Desc
+}
+
+// Collect the probed server metrics
+func (e *ProbeExporter) Collect(ch chan<- prometheus.Metric) {
+	success := 0.0
+
+	if e.server != nil {
+		success = 1.0
+
+		metadata := masterserver.MasterServerMetadata{
+			Protocol: ProbeProtocol,
+			Address:  e.target,
+		}
+
+		DefaultServerMetricsView.Send(
+			[]ServerEntry{{Metadata: metadata, Server: e.server}},
+			ch,
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		ProbeSuccessMetric.Desc,
+		ProbeSuccessMetric.Type,
+		success,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		ProbeDurationMetric.Desc,
+		ProbeDurationMetric.Type,
+		e.duration.Seconds(),
+	)
+}
+
+// Get the probe timeout, bounded by the Prometheus scrape timeout
+func probeTimeout(r *http.Request) time.Duration {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return gameserver.DefaultTimeout
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return gameserver.DefaultTimeout
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > gameserver.DefaultTimeout {
+		return gameserver.DefaultTimeout
+	}
+
+	return timeout
+}
+
+// HTTP handler probing the Teeworlds game server given by the `target`
+// and `protocol` query parameters, e.g. /probe?target=1.2.3.4:8303&protocol=0.7
+func ProbeHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	protocol, err := gameserver.ParseProtocol(params.Get("protocol"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout(r))
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewProbeExporter(ctx, target, protocol))
+
+	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+}
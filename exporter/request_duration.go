@@ -0,0 +1,33 @@
+package exporter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/config"
+)
+
+// Master server refresh duration Prometheus labels
+var RequestDurationLabels = append(append([]string{}, MasterServerLabels...), "phase")
+
+// Create the master server refresh duration histogram
+func NewRequestDurationHistogram(c config.RequestDuration) *prometheus.HistogramVec {
+	buckets := c.Buckets
+	if len(buckets) == 0 {
+		buckets = config.DefaultRequestDurationBuckets
+	}
+
+	opts := prometheus.HistogramOpts{
+		Name:    "teeworlds_master_server_request_duration_seconds",
+		Help:    "Duration of the master server refreshes, in total and per phase.",
+		Buckets: buckets,
+	}
+
+	if c.NativeHistogram {
+		opts.NativeHistogramBucketFactor = 1.1
+		opts.NativeHistogramMaxBucketNumber = 100
+		opts.NativeHistogramMinResetDuration = time.Hour
+	}
+
+	return prometheus.NewHistogramVec(opts, RequestDurationLabels)
+}
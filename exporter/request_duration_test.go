@@ -0,0 +1,39 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+)
+
+// Count the series of the refresh duration histogram
+func countRequestDuration(e *Exporter) int {
+	ch := make(chan prometheus.Metric, 16)
+	e.collectRequestDuration(ch)
+	close(ch)
+
+	return len(ch)
+}
+
+func TestExporterForgetMasterServer(t *testing.T) {
+	e := NewExporter(masterservers.NewMasterServerManager(), econ.NewEconManager(), nil)
+	kept := masterserver.MasterServerMetadata{Protocol: "udp", Address: "localhost:8283"}
+	removed := masterserver.MasterServerMetadata{Protocol: "udp", Address: "localhost:8284"}
+
+	// Forgetting a master server before any refresh does nothing
+	e.ForgetMasterServer(removed)
+
+	for _, metadata := range []masterserver.MasterServerMetadata{kept, removed} {
+		e.ObserveRefresh(metadata, masterservers.PhaseTotal, 0.5)
+		e.ObserveRefresh(metadata, "infos", 0.25)
+	}
+
+	e.ForgetMasterServer(removed)
+
+	if n := countRequestDuration(e); n != 2 {
+		t.Errorf("expected the 2 series of the kept master server, got %d", n)
+	}
+}
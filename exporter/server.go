@@ -4,97 +4,220 @@ import (
 	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/config"
+	twclient "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/client"
 	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
 	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
 )
 
 var (
 	// Teeworlds server Prometheus labels
-	ServerLabels = []string{
-		"name",
-		"address",
-		"gametype",
-		"max_players",
-		"password",
-		"map",
-		"version",
-		"master_server_protocol",
-		"master_server_address",
-	}
+	ServerLabels = config.ServerMetricsLabels
 
-	// Teeworlds master server metrics informations associated with function to scrape a metric
-	ServerMetrics = map[*MetricInfo]func(server *server.Server) float64{
+	// Teeworlds server metrics informations associated with function to scrape a metric
+	ServerMetrics = map[*ServerMetricInfo]func(server *server.Server) float64{
 		{
-			Desc: prometheus.NewDesc("teeworlds_server_players", "Total number of players in a Teeworlds server", ServerLabels, nil),
+			Name: "teeworlds_server_players",
+			Help: "Total number of clients in a Teeworlds server, spectators included.",
 			Type: prometheus.GaugeValue,
 		}: func(server *server.Server) float64 {
 			// Assuming server cannot be nil
 			return float64(len(server.Info.Clients))
 		},
+		{
+			Name: "teeworlds_server_ingame_players",
+			Help: "Number of in-game players in a Teeworlds server, spectators excluded.",
+			Type: prometheus.GaugeValue,
+		}: func(server *server.Server) float64 {
+			return countClients(server, func(client *twclient.Client) bool {
+				return client.IsPlayer
+			})
+		},
+		{
+			Name: "teeworlds_server_spectators",
+			Help: "Number of spectators in a Teeworlds server.",
+			Type: prometheus.GaugeValue,
+		}: func(server *server.Server) float64 {
+			return countClients(server, func(client *twclient.Client) bool {
+				return !client.IsPlayer
+			})
+		},
+		{
+			Name: "teeworlds_server_afk_players",
+			Help: "Number of AFK clients in a Teeworlds server, only known from the DDNet HTTP master servers.",
+			Type: prometheus.GaugeValue,
+			Known: func(server *server.Server) bool {
+				return server.Info.AfkKnown
+			},
+		}: func(server *server.Server) float64 {
+			return countClients(server, func(client *twclient.Client) bool {
+				return client.Afk
+			})
+		},
+		{
+			Name: "teeworlds_server_bots",
+			Help: "Number of bots in a Teeworlds server, only known from the Teeworlds 0.7 servers.",
+			Type: prometheus.GaugeValue,
+			Known: func(server *server.Server) bool {
+				return server.Info.BotsKnown
+			},
+		}: func(server *server.Server) float64 {
+			return countClients(server, func(client *twclient.Client) bool {
+				return client.IsBot
+			})
+		},
+		{
+			Name: "teeworlds_server_max_players",
+			Help: "Maximum number of in-game players in a Teeworlds server.",
+			Type: prometheus.GaugeValue,
+		}: func(server *server.Server) float64 {
+			return float64(server.Info.MaxPlayers)
+		},
+		{
+			Name: "teeworlds_server_max_clients",
+			Help: "Maximum number of clients in a Teeworlds server, spectators included.",
+			Type: prometheus.GaugeValue,
+		}: func(server *server.Server) float64 {
+			return float64(server.Info.MaxClients)
+		},
+		{
+			Name: "teeworlds_server_fill_ratio",
+			Help: "Ratio between the number of clients and the maximum number of clients of a Teeworlds server.",
+			Type: prometheus.GaugeValue,
+		}: func(server *server.Server) float64 {
+			if server.Info.MaxClients <= 0 {
+				return 0
+			}
+
+			return float64(len(server.Info.Clients)) / float64(server.Info.MaxClients)
+		},
 	}
-)
 
-// Send Teeworlds servers Prometheus metric
-func SendServerMetrics(
-	metricInfo *MetricInfo,
-	msm *masterservers.MasterServerManager,
-	ch chan<- prometheus.Metric,
-	f func(server *server.Server) float64,
-) error {
-	if msm == nil || metricInfo == nil {
-		return fmt.Errorf("missing master servers and metric info")
+	// Number of master servers listing a Teeworlds server, in the merged view
+	ServerSeenOnMetric = ServerMetricInfo{
+		Name: "teeworlds_server_seen_on",
+		Help: "Number of master servers listing a Teeworlds server.",
+		Type: prometheus.GaugeValue,
 	}
 
-	masterServers := msm.MasterServers()
+	// Teeworlds server informations metric, with the configured info labels
+	ServerInfoMetric = ServerMetricInfo{
+		Name: "teeworlds_server_info",
+		Help: "Teeworlds server informations, joined to the other metrics by address.",
+		Type: prometheus.GaugeValue,
+	}
 
-	var passworded string
+	// Number of servers not exported because of the servers limit
+	ServersDroppedMetric = MetricInfo{
+		Desc: prometheus.NewDesc("teeworlds_exporter_servers_dropped", "Number of Teeworlds servers not exported because of the max_servers limit.", nil, nil),
+		Type: prometheus.GaugeValue,
+	}
+)
 
-	for _, masterServer := range masterServers {
-		if masterServer == nil {
-			continue
-		}
+// Teeworlds server metric informations, its description depends on the
+// exported labels
+type ServerMetricInfo struct {
+	// Prometheus metric name
+	Name string
+	// Prometheus metric help
+	Help string
+	// Prometheus metric type
+	Type prometheus.ValueType
+	// Whether the metric is known for a server, always if nil
+	Known func(server *server.Server) bool
+}
 
-		metadata := (*masterServer).Metadata()
+// Teeworlds server to export, with the master servers listing it
+type ServerEntry struct {
+	// Master server listing the server, empty in the merged view
+	Metadata masterserver.MasterServerMetadata
+	// Server informations
+	Server *server.Server
+	// Number of master servers listing the server, zero
+	// if the servers are not merged
+	SeenOn int
+}
 
-		servers, err := (*masterServer).Servers()
-		if err != nil {
-			continue
+// Count the clients of a server matching `f`
+func countClients(server *server.Server, f func(client *twclient.Client) bool) float64 {
+	n := 0
+
+	for i := range server.Info.Clients {
+		if f(&server.Info.Clients[i]) {
+			n++
 		}
+	}
 
-		for _, server := range servers {
-			if server == nil || len(server.Addresses) == 0 {
-				continue
-			}
+	return float64(n)
+}
 
-			if server.Info.Passworded {
-				passworded = "true"
-			} else {
-				passworded = "false"
-			}
+// Get every label value of a server, in the `ServerLabels` order
+func ServerLabelValues(
+	metadata masterserver.MasterServerMetadata,
+	server *server.Server,
+) ([]string, error) {
+	if server == nil {
+		return nil, fmt.Errorf("missing server")
+	}
 
-			labelValues := []string{
-				server.Info.Name,
-				server.Addresses[0],
-				server.Info.GameType,
-				fmt.Sprintf("%d", server.Info.MaxPlayers),
-				passworded,
-				server.Info.Map.Name,
-				server.Info.Version,
-				metadata.Protocol,
-				metadata.Address,
-			}
+	addresses := server.ParsedAddresses()
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("missing server address")
+	}
+
+	address := addresses[0]
+
+	var passworded string
+
+	if server.Info.Passworded {
+		passworded = "true"
+	} else {
+		passworded = "false"
+	}
 
-			metricValue := f(server)
+	return []string{
+		server.Info.Name,
+		address.HostPort(),
+		address.Host,
+		fmt.Sprintf("%d", address.Port),
+		address.NetProtocol(),
+		server.Info.GameType,
+		passworded,
+		server.Info.Map.Name,
+		server.Info.Version,
+		metadata.Protocol,
+		metadata.Address,
+	}, nil
+}
 
-			ch <- prometheus.MustNewConstMetric(
-				metricInfo.Desc,
-				metricInfo.Type,
-				metricValue,
-				labelValues...,
-			)
+// Get the servers to export from the snapshots, one entry per master server
+// listing a server, or one entry per server if `merge` is true
+func ServerEntries(
+	snapshots []masterserver.MasterServerSnapshot,
+	merge bool,
+) []ServerEntry {
+	var entries []ServerEntry
+
+	if merge {
+		for _, mergedServer := range masterservers.MergeSnapshots(snapshots) {
+			entries = append(entries, ServerEntry{
+				Server: mergedServer.Server,
+				SeenOn: len(mergedServer.SeenOn),
+			})
+		}
+
+		return entries
+	}
 
+	for _, snapshot := range snapshots {
+		for _, server := range snapshot.Servers {
+			entries = append(entries, ServerEntry{
+				Metadata: snapshot.Metadata,
+				Server:   server,
+			})
 		}
 	}
 
-	return nil
+	return entries
 }
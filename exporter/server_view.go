@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/config"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+// Separator of the label values within a deduplication key
+const labelValuesSeparator = "\xff"
+
+var (
+	// View exporting every label without any limit
+	DefaultServerMetricsView = NewServerMetricsView(config.ServerMetrics{})
+)
+
+// Labels and cardinality of the teeworlds_server_* metrics
+type ServerMetricsView struct {
+	// Index of each exported label in `ServerLabels`
+	indexes []int
+	// Index of each info label in `ServerLabels`
+	infoIndexes []int
+	// Maximum number of exported servers, unlimited if zero
+	maxServers uint
+	// Metric descriptions with the exported labels
+	descs map[*ServerMetricInfo]*prometheus.Desc
+	// Number of master servers listing a server description
+	seenOnDesc *prometheus.Desc
+	// Info metric description, nil if there is no info label
+	infoDesc *prometheus.Desc
+}
+
+// Get the indexes of the selected labels in `ServerLabels`, the labels
+// identifying a server are always selected
+func serverLabelIndexes(selected []string) ([]string, []int) {
+	var labels []string
+	var indexes []int
+
+	for i, label := range ServerLabels {
+		if slices.Contains(selected, label) ||
+			slices.Contains(config.ServerMetricsRequiredLabels, label) {
+			labels = append(labels, label)
+			indexes = append(indexes, i)
+		}
+	}
+
+	return labels, indexes
+}
+
+// Create a view from the server metrics configuration
+func NewServerMetricsView(c config.ServerMetrics) *ServerMetricsView {
+	selected := c.Labels
+	if len(selected) == 0 {
+		selected = ServerLabels
+	}
+
+	labels, indexes := serverLabelIndexes(selected)
+
+	v := &ServerMetricsView{
+		indexes:    indexes,
+		maxServers: c.MaxServers,
+		descs:      make(map[*ServerMetricInfo]*prometheus.Desc),
+		seenOnDesc: prometheus.NewDesc(ServerSeenOnMetric.Name, ServerSeenOnMetric.Help, labels, nil),
+	}
+
+	for metricInfo := range ServerMetrics {
+		v.descs[metricInfo] = prometheus.NewDesc(metricInfo.Name, metricInfo.Help, labels, nil)
+	}
+
+	if len(c.InfoLabels) > 0 {
+		infoLabels, infoIndexes := serverLabelIndexes(c.InfoLabels)
+
+		v.infoIndexes = infoIndexes
+		v.infoDesc = prometheus.NewDesc(ServerInfoMetric.Name, ServerInfoMetric.Help, infoLabels, nil)
+	}
+
+	return v
+}
+
+// Send the descriptions of the server metrics
+func (v *ServerMetricsView) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range v.descs {
+		ch <- desc
+	}
+}
+
+// Select label values by their index
+func selectLabelValues(values []string, indexes []int) []string {
+	selected := make([]string, len(indexes))
+
+	for i, index := range indexes {
+		selected[i] = values[index]
+	}
+
+	return selected
+}
+
+// Get the first address of a server, used to sort the servers
+func firstAddress(s *server.Server) string {
+	if len(s.Addresses) == 0 {
+		return ""
+	}
+
+	return server.NormalizeAddress(s.Addresses[0])
+}
+
+// Keep the `maxServers` most populated servers, it returns the kept
+// servers and the number of dropped ones. The ties are broken by address,
+// so the same servers are kept across the scrapes.
+func (v *ServerMetricsView) limit(entries []ServerEntry) ([]ServerEntry, int) {
+	if v.maxServers == 0 || uint(len(entries)) <= v.maxServers {
+		return entries, 0
+	}
+
+	sorted := slices.Clone(entries)
+	slices.SortFunc(sorted, func(a, b ServerEntry) int {
+		return cmp.Or(
+			cmp.Compare(len(b.Server.Info.Clients), len(a.Server.Info.Clients)),
+			cmp.Compare(firstAddress(a.Server), firstAddress(b.Server)),
+			cmp.Compare(a.Metadata.Protocol, b.Metadata.Protocol),
+			cmp.Compare(a.Metadata.Address, b.Metadata.Address),
+		)
+	})
+
+	return sorted[:v.maxServers], len(sorted) - int(v.maxServers)
+}
+
+// Send the metrics of the servers, it returns the number of servers
+// dropped because of the servers limit
+func (v *ServerMetricsView) Send(entries []ServerEntry, ch chan<- prometheus.Metric) int {
+	var unique []ServerEntry
+
+	// The same server may be listed twice by a master server, it is
+	// deduplicated before the limit so it is not counted as dropped
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		// Skipping the servers without address
+		values, err := ServerLabelValues(entry.Metadata, entry.Server)
+		if err != nil {
+			continue
+		}
+
+		labelValues := selectLabelValues(values, v.indexes)
+
+		key := strings.Join(labelValues, labelValuesSeparator)
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		unique = append(unique, entry)
+	}
+
+	kept, dropped := v.limit(unique)
+
+	for _, entry := range kept {
+		values, err := ServerLabelValues(entry.Metadata, entry.Server)
+		if err != nil {
+			continue
+		}
+
+		labelValues := selectLabelValues(values, v.indexes)
+
+		for metricInfo, f := range ServerMetrics {
+			if metricInfo.Known != nil && !metricInfo.Known(entry.Server) {
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				v.descs[metricInfo],
+				metricInfo.Type,
+				f(entry.Server),
+				labelValues...,
+			)
+		}
+
+		if entry.SeenOn > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				v.seenOnDesc,
+				ServerSeenOnMetric.Type,
+				float64(entry.SeenOn),
+				labelValues...,
+			)
+		}
+
+		if v.infoDesc != nil {
+			ch <- prometheus.MustNewConstMetric(
+				v.infoDesc,
+				ServerInfoMetric.Type,
+				1,
+				selectLabelValues(values, v.infoIndexes)...,
+			)
+		}
+	}
+
+	return dropped
+}
@@ -0,0 +1,152 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/config"
+	twclient "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/client"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+// Collect the metrics sent by a view
+func sendView(v *ServerMetricsView, entries []ServerEntry) ([]*dto.Metric, int) {
+	ch := make(chan prometheus.Metric, 1024)
+	dropped := v.Send(entries, ch)
+	close(ch)
+
+	var metrics []*dto.Metric
+
+	for metric := range ch {
+		m := &dto.Metric{}
+		_ = metric.Write(m)
+		metrics = append(metrics, m)
+	}
+
+	return metrics, dropped
+}
+
+// Create a server with `clients` clients
+func newServer(address string, clients int) *server.Server {
+	return &server.Server{
+		Addresses: []string{address},
+		Info: server.ServerInfo{
+			Name:    "server " + address,
+			Clients: make([]twclient.Client, clients),
+		},
+	}
+}
+
+func TestServerMetricsViewLabels(t *testing.T) {
+	v := NewServerMetricsView(config.ServerMetrics{
+		Labels:     []string{"gametype"},
+		InfoLabels: []string{"name"},
+	})
+
+	metadata := masterserver.MasterServerMetadata{Protocol: "udp", Address: "master1.teeworlds.com:8283"}
+	s := newServer("tw-0.7+udp://1.2.3.4:8303", 1)
+	s.Info.BotsKnown = true
+
+	entries := []ServerEntry{
+		{Metadata: metadata, Server: s},
+		// Listed twice by the same master server
+		{Metadata: metadata, Server: s},
+	}
+
+	metrics, dropped := sendView(v, entries)
+
+	if dropped != 0 {
+		t.Errorf("expected no dropped server, got %d", dropped)
+	}
+
+	// Every server metric but the AFK players, unknown from
+	// a UDP master server, and the info metric, once
+	if len(metrics) != len(ServerMetrics) {
+		t.Fatalf("expected %d metrics, got %d", len(ServerMetrics), len(metrics))
+	}
+
+	for _, m := range metrics {
+		names := []string{}
+
+		for _, label := range m.GetLabel() {
+			names = append(names, label.GetName())
+		}
+
+		if len(names) != 4 {
+			t.Errorf("unexpected labels %v", names)
+		}
+	}
+}
+
+func TestServerMetricsViewKnown(t *testing.T) {
+	s := newServer("1.2.3.4:8303", 1)
+
+	// Every server metric but the AFK players and the bots
+	metrics, _ := sendView(DefaultServerMetricsView, []ServerEntry{{Server: s}})
+
+	if len(metrics) != len(ServerMetrics)-2 {
+		t.Errorf("expected %d metrics, got %d", len(ServerMetrics)-2, len(metrics))
+	}
+
+	s.Info.AfkKnown = true
+	s.Info.BotsKnown = true
+
+	metrics, _ = sendView(DefaultServerMetricsView, []ServerEntry{{Server: s}})
+
+	if len(metrics) != len(ServerMetrics) {
+		t.Errorf("expected %d metrics, got %d", len(ServerMetrics), len(metrics))
+	}
+}
+
+func TestServerMetricsViewLimit(t *testing.T) {
+	v := NewServerMetricsView(config.ServerMetrics{MaxServers: 2})
+
+	entries := []ServerEntry{
+		{Server: newServer("1.1.1.1:8303", 1)},
+		{Server: newServer("2.2.2.2:8303", 8)},
+		{Server: newServer("3.3.3.3:8303", 4)},
+	}
+
+	kept, dropped := v.limit(entries)
+
+	if dropped != 1 || len(kept) != 2 {
+		t.Fatalf("expected 2 kept and 1 dropped servers, got %d and %d", len(kept), dropped)
+	}
+
+	if kept[0].Server != entries[1].Server || kept[1].Server != entries[2].Server {
+		t.Error("expected the most populated servers to be kept")
+	}
+}
+
+func TestServerMetricsViewDuplicatesNotDropped(t *testing.T) {
+	v := NewServerMetricsView(config.ServerMetrics{MaxServers: 1})
+
+	metadata := masterserver.MasterServerMetadata{Protocol: "udp", Address: "master1.teeworlds.com:8283"}
+	entries := []ServerEntry{
+		{Metadata: metadata, Server: newServer("1.2.3.4:8303", 1)},
+		// Listed twice by the same master server
+		{Metadata: metadata, Server: newServer("1.2.3.4:8303", 1)},
+	}
+
+	if _, dropped := sendView(v, entries); dropped != 0 {
+		t.Errorf("expected no dropped server, got %d", dropped)
+	}
+}
+
+func TestExporterRegister(t *testing.T) {
+	msm := masterservers.NewMasterServerManager()
+	registry := prometheus.NewPedanticRegistry()
+
+	registry.MustRegister(
+		NewExporter(msm, econ.NewEconManager(), nil),
+		NewEconLabelsExporter(econ.NewEconManager()),
+	)
+
+	if _, err := registry.Gather(); err != nil {
+		t.Error(err)
+	}
+}
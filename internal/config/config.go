@@ -1,33 +1,187 @@
 package config
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"slices"
 
+	econ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+	gameserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/game_server"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	mgame "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/game"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	Servers Servers `yaml:"servers"`
+	// Econ events shared by every econ server
+	Events []EconEvent `yaml:"events,omitempty"`
+	// Whether the built-in econ events are registered, true by default
+	DefaultEvents *bool `yaml:"default_events,omitempty"`
+	// Player statistics of every econ server
+	PlayerStats *PlayerStats `yaml:"player_stats,omitempty"`
+	// Labels and cardinality of the teeworlds_server_* metrics
+	ServerMetrics ServerMetrics `yaml:"server_metrics,omitempty"`
+	// Servers exported from every master server and game server
+	Filter *ServerFilter `yaml:"filter,omitempty"`
+	// Players distribution metrics
+	PlayerDistribution PlayerDistribution `yaml:"player_distribution,omitempty"`
+	// Master server refresh duration histogram
+	RequestDuration RequestDuration `yaml:"request_duration,omitempty"`
 }
 
 type Servers struct {
 	Econ   []EconServer   `yaml:"econ"`
 	Master []MasterServer `yaml:"master"`
+	Game   []GameServer   `yaml:"game"`
 }
 
 type EconServer struct {
 	Host     string `yaml:"host"`
 	Port     uint16 `yaml:"port"`
 	Password string `yaml:"password"`
+	// Econ events specific to this econ server
+	Events []EconEvent `yaml:"events,omitempty"`
+	// Overrides the global `default_events`
+	DefaultEvents *bool `yaml:"default_events,omitempty"`
+	// Overrides the global `player_stats`
+	PlayerStats *PlayerStats `yaml:"player_stats,omitempty"`
 }
 
+type PlayerStats struct {
+	Enabled bool `yaml:"enabled"`
+	// Minutes after which an inactive player is evicted,
+	// `econ.DefaultPlayerInactivityTimeout` if zero
+	InactivityTimeout uint `yaml:"inactivity_timeout,omitempty"`
+}
+
+type EconEvent struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+	// Maximum number of label sets built from the named capture groups
+	MaxLabelSets uint `yaml:"max_label_sets,omitempty"`
+}
+
+var (
+	// Valid Prometheus metric and label name part
+	prometheusNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// Labels already used by the labeled econ event metrics
+	econEventReservedLabels = []string{"address", "port"}
+
+	// Event names whose labeled metric would collide with a built-in one
+	econEventReservedNames = []string{"label_sets_dropped"}
+)
+
+type ServerMetrics struct {
+	// Labels of the teeworlds_server_* metrics, every label if empty
+	Labels []string `yaml:"labels,omitempty"`
+	// Labels of the teeworlds_server_info metric, not exported if empty
+	InfoLabels []string `yaml:"info_labels,omitempty"`
+	// Maximum number of exported servers, the most populated ones are kept
+	MaxServers uint `yaml:"max_servers,omitempty"`
+}
+
+type PlayerDistribution struct {
+	// Number of clans with the most players exported, none if zero
+	TopClans uint `yaml:"top_clans,omitempty"`
+}
+
+var (
+	// Labels of the teeworlds_server_* metrics
+	ServerMetricsLabels = []string{
+		"name",
+		"address",
+		"ip",
+		"port",
+		"net_protocol",
+		"gametype",
+		"password",
+		"map",
+		"version",
+		"master_server_protocol",
+		"master_server_address",
+	}
+
+	// Labels identifying a server, they are always exported
+	ServerMetricsRequiredLabels = []string{
+		"address",
+		"master_server_protocol",
+		"master_server_address",
+	}
+)
+
+type RequestDuration struct {
+	// Histogram buckets in seconds, `DefaultRequestDurationBuckets` if empty
+	Buckets []float64 `yaml:"buckets,omitempty"`
+	// Whether the histogram is also exposed as a native histogram
+	NativeHistogram bool `yaml:"native_histogram,omitempty"`
+}
+
+// Default master server refresh duration histogram buckets
+var DefaultRequestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
 type MasterServer struct {
 	Protocol        string `yaml:"protocol"`
-	URL             string `yaml:"url,omitempty"`
-	Host            string `yaml:"host,omitempty"`
-	Port            uint16 `yaml:"port,omitempty"`
 	RefreshCooldown uint   `yaml:"refresh_cooldown" default:"10"`
+	// Age in seconds of the last successful refresh after which
+	// the servers are not exported, never if zero
+	StaleAfter uint `yaml:"stale_after,omitempty"`
+	// Servers exported from this master server, on top of the global filter
+	Filter *ServerFilter `yaml:"filter,omitempty"`
+	// Whole YAML node of the entry, decoded by the protocol
+	Node *yaml.Node `yaml:"-"`
+}
+
+// Decode the common master server keys, and keep the whole node
+// for the protocol
+func (m *MasterServer) UnmarshalYAML(node *yaml.Node) error {
+	// Without the methods, to not call `UnmarshalYAML` again
+	type plain MasterServer
+
+	if err := node.Decode((*plain)(m)); err != nil {
+		return err
+	}
+
+	m.Node = normalizeNode(node)
+
+	return nil
+}
+
+// Copy a YAML node without its position and comments, so the same
+// configuration at another place of the file is equal
+func normalizeNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	normalized := *node
+	normalized.Line = 0
+	normalized.Column = 0
+	normalized.HeadComment = ""
+	normalized.LineComment = ""
+	normalized.FootComment = ""
+	normalized.Alias = normalizeNode(node.Alias)
+	normalized.Content = nil
+
+	for _, child := range node.Content {
+		normalized.Content = append(normalized.Content, normalizeNode(child))
+	}
+
+	return &normalized
+}
+
+type GameServer struct {
+	Host            string `yaml:"host"`
+	Port            uint16 `yaml:"port"`
+	Protocol        string `yaml:"protocol"`
+	RefreshCooldown uint   `yaml:"refresh_cooldown" default:"10"`
+	// Age in seconds of the last successful refresh after which
+	// the server is not exported, never if zero
+	StaleAfter uint `yaml:"stale_after,omitempty"`
 }
 
 // Get YAML data as `Config`
@@ -39,9 +193,242 @@ func ConfigFromData(data []byte) (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+// Check a list of econ events
+func validateEconEvents(events []EconEvent) error {
+	names := make(map[string]bool)
+
+	for _, event := range events {
+		if event.Name == "" {
+			return fmt.Errorf("missing econ event name")
+		}
+
+		if names[event.Name] {
+			return fmt.Errorf("duplicated econ event %q", event.Name)
+		}
+
+		names[event.Name] = true
+
+		re, err := regexp.Compile(event.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex for the econ event %q: %v", event.Name, err)
+		}
+
+		labels := econ.NamedGroups(re)
+		if len(labels) == 0 {
+			continue
+		}
+
+		// The event name is part of the labeled metric name
+		if !prometheusNameRegex.MatchString(event.Name) || slices.Contains(econEventReservedNames, event.Name) {
+			return fmt.Errorf("invalid econ event name %q for a regex with named groups", event.Name)
+		}
+
+		for _, label := range labels {
+			if !prometheusNameRegex.MatchString(label) || slices.Contains(econEventReservedLabels, label) {
+				return fmt.Errorf("invalid named group %q for the econ event %q", label, event.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check that the events with the same name have the same named groups
+// across the econ servers, they share the same labeled metric
+func validateEconEventsLabels(econServers []EconServer) error {
+	labelsByName := make(map[string][]string)
+
+	for _, econServer := range econServers {
+		for _, event := range econServer.Events {
+			labels := econ.NamedGroups(regexp.MustCompile(event.Regex))
+
+			previous, found := labelsByName[event.Name]
+			if found && !slices.Equal(previous, labels) {
+				return fmt.Errorf("the econ event %q has different named groups across the econ servers", event.Name)
+			}
+
+			labelsByName[event.Name] = labels
+		}
+	}
+
+	return nil
+}
+
+// Check a list of teeworlds_server_* labels
+func validateServerMetricsLabels(labels []string) error {
+	seen := make(map[string]bool)
+
+	for _, label := range labels {
+		if !slices.Contains(ServerMetricsLabels, label) {
+			return fmt.Errorf("unknown server metrics label %q", label)
+		}
+
+		if seen[label] {
+			return fmt.Errorf("duplicated server metrics label %q", label)
+		}
+
+		seen[label] = true
+	}
+
+	return nil
+}
+
+// Check the master server protocols, and that a master server is configured
+// once as its metadata identifies it, an url of the mirrored master servers
+// is also used by one master server only as it labels its metrics
+func validateMasterServers(
+	masterServers []MasterServer,
+	seen map[masterserver.MasterServerMetadata]bool,
+) error {
+	seenUrls := make(map[string]bool)
+	protocols := masterservers.Protocols()
+
+	for _, masterServer := range masterServers {
+		if !slices.Contains(protocols, masterServer.Protocol) {
+			return fmt.Errorf(
+				"invalid master server protocol %q, expected one of %v",
+				masterServer.Protocol,
+				protocols,
+			)
+		}
+
+		if _, err := compileServerFilter(masterServer.Filter); err != nil {
+			return err
+		}
+
+		if masterServer.Node == nil {
+			continue
+		}
+
+		// Nothing is connected until the master server entry is created
+		ms, err := masterservers.NewProtocolMasterServer(
+			masterServer.Protocol,
+			masterServer.Node,
+		)
+		if err != nil {
+			return err
+		}
+
+		metadata := ms.Metadata()
+		if seen[metadata] {
+			return fmt.Errorf(
+				"duplicated master server %s with protocol %s",
+				metadata.Address,
+				metadata.Protocol,
+			)
+		}
+
+		seen[metadata] = true
+
+		mirrorer, ok := ms.(masterserver.Mirrorer)
+		if !ok {
+			continue
+		}
+
+		for _, u := range mirrorer.Urls() {
+			if seenUrls[u] {
+				return fmt.Errorf("duplicated master server url %q", u)
+			}
+
+			seenUrls[u] = true
+		}
+	}
+
+	return nil
+}
+
+// Check the game server protocols, and that a game server is configured
+// once as its metadata identifies it
+func validateGameServers(
+	gameServers []GameServer,
+	seen map[masterserver.MasterServerMetadata]bool,
+) error {
+	for _, gameServer := range gameServers {
+		protocol, err := gameserver.ParseProtocol(gameServer.Protocol)
+		if err != nil {
+			return err
+		}
+
+		metadata := mgame.NewMasterServer(
+			gameServer.Host,
+			gameServer.Port,
+			protocol,
+		).Metadata()
+
+		if seen[metadata] {
+			return fmt.Errorf("duplicated game server %s", metadata.Address)
+		}
+
+		seen[metadata] = true
+	}
+
+	return nil
+}
+
+// Check the request duration histogram buckets, they must be
+// positive and strictly increasing
+func validateRequestDuration(requestDuration RequestDuration) error {
+	for i, bucket := range requestDuration.Buckets {
+		if bucket <= 0 {
+			return fmt.Errorf("invalid request duration bucket %v", bucket)
+		}
+
+		if i > 0 && bucket <= requestDuration.Buckets[i-1] {
+			return fmt.Errorf("request duration buckets are not strictly increasing")
+		}
+	}
+
+	return nil
+}
+
+// Check the configuration values that cannot be checked by the YAML decoding
+func (c *Config) Validate() error {
+	if err := validateServerMetricsLabels(c.ServerMetrics.Labels); err != nil {
+		return err
+	}
+
+	if err := validateServerMetricsLabels(c.ServerMetrics.InfoLabels); err != nil {
+		return err
+	}
+
+	if err := validateRequestDuration(c.RequestDuration); err != nil {
+		return err
+	}
+
+	if _, err := compileServerFilter(c.Filter); err != nil {
+		return err
+	}
+
+	seen := make(map[masterserver.MasterServerMetadata]bool)
+
+	if err := validateMasterServers(c.Servers.Master, seen); err != nil {
+		return err
+	}
+
+	if err := validateGameServers(c.Servers.Game, seen); err != nil {
+		return err
+	}
+
+	if err := validateEconEvents(c.Events); err != nil {
+		return err
+	}
+
+	for _, econServer := range c.Servers.Econ {
+		if err := validateEconEvents(econServer.Events); err != nil {
+			return err
+		}
+	}
+
+	return validateEconEventsLabels(resolveEconServers(*c))
+}
+
 // Get YAML data as `Config` from a file
 func ConfigFromFile(filename string) (*Config, error) {
 	file, err := os.Open(filename)
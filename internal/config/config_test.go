@@ -0,0 +1,357 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigFromDataInvalidRegex(t *testing.T) {
+	data := []byte(`
+events:
+  - name: vote
+    regex: "[vote"
+`)
+
+	if _, err := ConfigFromData(data); err == nil {
+		t.Error("expected an invalid regex error")
+	}
+}
+
+func TestConfigFromDataReservedEventName(t *testing.T) {
+	data := []byte(`
+events:
+  - name: label_sets_dropped
+    regex: "kill killer='(?P<killer>[^']*)'"
+`)
+
+	if _, err := ConfigFromData(data); err == nil {
+		t.Error("expected a reserved event name error")
+	}
+}
+
+func TestConfigFromDataServerMetricsLabels(t *testing.T) {
+	data := []byte(`
+server_metrics:
+  labels: [address, gametype]
+  info_labels: [name, map]
+  max_servers: 500
+`)
+
+	if _, err := ConfigFromData(data); err != nil {
+		t.Error(err)
+	}
+
+	data = []byte(`
+server_metrics:
+  labels: [address, players]
+`)
+
+	if _, err := ConfigFromData(data); err == nil {
+		t.Error("expected an unknown label error")
+	}
+}
+
+func TestConfigFromDataInvalidFilter(t *testing.T) {
+	data := []byte(`
+servers:
+  master:
+    - protocol: http
+      url: https://master1.ddnet.tw/ddnet/15/servers.json
+      filter:
+        include:
+          networks: [10.0.0.0/33]
+`)
+
+	if _, err := ConfigFromData(data); err == nil {
+		t.Error("expected an invalid network error")
+	}
+}
+
+func TestConfigFromDataRequestDuration(t *testing.T) {
+	valid := []byte(`
+request_duration:
+  buckets: [0.1, 0.5, 1]
+  native_histogram: true
+`)
+
+	c, err := ConfigFromData(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.RequestDuration.Buckets) != 3 || !c.RequestDuration.NativeHistogram {
+		t.Errorf("unexpected request duration %+v", c.RequestDuration)
+	}
+
+	for _, buckets := range []string{"[1, 0.5]", "[0, 1]", "[1, 1]"} {
+		data := []byte("request_duration:\n  buckets: " + buckets + "\n")
+
+		if _, err := ConfigFromData(data); err == nil {
+			t.Errorf("expected an invalid buckets error for %s", buckets)
+		}
+	}
+}
+
+func TestConfigFromDataMasterServerURLs(t *testing.T) {
+	valid := []byte(`
+servers:
+  master:
+    - protocol: http
+      urls:
+        - https://master1.ddnet.tw/ddnet/15/servers.json
+        - https://master2.ddnet.tw/ddnet/15/servers.json
+      strategy: round_robin
+`)
+
+	if _, err := ConfigFromData(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []string{
+		// Both url and urls
+		`
+servers:
+  master:
+    - protocol: http
+      url: https://master1.ddnet.tw/ddnet/15/servers.json
+      urls: [https://master2.ddnet.tw/ddnet/15/servers.json]
+`,
+		// Url shared by two master servers
+		`
+servers:
+  master:
+    - protocol: http
+      urls: [https://master1.ddnet.tw/ddnet/15/servers.json, https://master2.ddnet.tw/ddnet/15/servers.json]
+    - protocol: http
+      url: https://master2.ddnet.tw/ddnet/15/servers.json
+`,
+		// Unknown strategy
+		`
+servers:
+  master:
+    - protocol: http
+      urls: [https://master1.ddnet.tw/ddnet/15/servers.json]
+      strategy: random
+`,
+	}
+
+	for _, data := range invalid {
+		if _, err := ConfigFromData([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
+
+func TestConfigFromDataDuplicatedServers(t *testing.T) {
+	valid := []byte(`
+servers:
+  master:
+    - protocol: udp
+      host: master1.teeworlds.com
+      port: 8300
+    - protocol: udp
+      host: master2.teeworlds.com
+      port: 8300
+  game:
+    - host: 127.0.0.1
+      port: 8303
+    - host: 127.0.0.1
+      port: 8304
+`)
+
+	if _, err := ConfigFromData(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []string{
+		// UDP master server configured twice
+		`
+servers:
+  master:
+    - protocol: udp
+      host: master1.teeworlds.com
+      port: 8300
+    - protocol: udp
+      host: master1.teeworlds.com
+      port: 8300
+      refresh_cooldown: 30
+`,
+		// Game server configured twice, whatever its network protocol
+		`
+servers:
+  game:
+    - host: 127.0.0.1
+      port: 8303
+    - host: 127.0.0.1
+      port: 8303
+      protocol: "0.7"
+`,
+	}
+
+	for _, data := range invalid {
+		if _, err := ConfigFromData([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
+
+func TestConfigFromDataMasterServerHTTPClient(t *testing.T) {
+	valid := []byte(`
+servers:
+  master:
+    - protocol: http
+      url: https://servers.example.com/servers.json
+      timeout: 5
+      proxy_url: http://proxy.example.com:3128
+      tls:
+        insecure_skip_verify: true
+      user_agent: teeworlds-exporter
+      headers:
+        Authorization: Bearer token
+`)
+
+	c, err := ConfigFromData(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newMasterServerEntry(c.Servers.Master[0], false); err != nil {
+		t.Error(err)
+	}
+
+	for _, option := range []string{
+		"proxy_url: proxy.example.com",
+		"timeout: 5s",
+		"headers: {accept-encoding: br}",
+		"tls: {ca_file: /missing/ca.pem}",
+	} {
+		data := []byte(`
+servers:
+  master:
+    - protocol: http
+      url: https://servers.example.com/servers.json
+      ` + option + `
+`)
+
+		c, err := ConfigFromData(data)
+		if err == nil {
+			_, err = newMasterServerEntry(c.Servers.Master[0], false)
+		}
+
+		if err == nil {
+			t.Errorf("expected an error for %s", option)
+		}
+	}
+}
+
+func TestConfigFromDataMasterServerNode(t *testing.T) {
+	a, err := ConfigFromData([]byte(`
+servers:
+  master:
+    - protocol: udp
+      host: master1.teeworlds.com
+      port: 8283
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ConfigFromData([]byte(`
+# Moved and commented
+servers:
+
+  master:
+    - protocol: udp # the 0.7 master server
+      host: master1.teeworlds.com
+      port: 8283
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(a.Servers.Master, b.Servers.Master) {
+		t.Error("the same master server configuration must be equal")
+	}
+
+	if _, err := ConfigFromData([]byte("servers:\n  master:\n    - protocol: kog\n")); err == nil {
+		t.Error("expected an unknown protocol error")
+	}
+}
+
+func TestResolveEconServers(t *testing.T) {
+	data := []byte(`
+events:
+  - name: join
+    regex: 'player has entered the game'
+servers:
+  econ:
+    - host: localhost
+      port: 7000
+      events:
+        - name: kill
+          regex: 'kill killer='
+    - host: localhost
+      port: 7001
+      default_events: false
+`)
+
+	c, err := ConfigFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	econServers := resolveEconServers(*c)
+
+	// message, captured_flag, join and the overridden kill
+	if len(econServers[0].Events) != 4 {
+		t.Errorf("expected 4 events, got %v", econServers[0].Events)
+	}
+
+	for _, event := range econServers[0].Events {
+		if event.Name == "kill" && event.Regex != "kill killer=" {
+			t.Errorf("the kill event has not been overridden")
+		}
+	}
+
+	if len(econServers[1].Events) != 1 || econServers[1].Events[0].Name != "join" {
+		t.Errorf("expected only the join event, got %v", econServers[1].Events)
+	}
+}
+
+func TestConfigFromDataStaleAfter(t *testing.T) {
+	data := []byte(`
+servers:
+  game:
+    - host: 127.0.0.1
+      port: 8303
+      stale_after: 120
+`)
+
+	c, err := ConfigFromData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := newGameServerEntry(c.Servers.Game[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.StaleAfter != 2*time.Minute {
+		t.Errorf("expected 2m, got %v", entry.StaleAfter)
+	}
+
+	// The duration is in seconds, like refresh_cooldown
+	data = []byte(`
+servers:
+  game:
+    - host: 127.0.0.1
+      port: 8303
+      stale_after: 2m
+`)
+
+	if _, err := ConfigFromData(data); err == nil {
+		t.Error("expected an invalid stale_after error")
+	}
+}
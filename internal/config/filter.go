@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+type ServerFilterRule struct {
+	GameTypes []string `yaml:"gametypes,omitempty"`
+	// Server name regexes
+	Names []string `yaml:"names,omitempty"`
+	// Server version regexes
+	Versions    []string `yaml:"versions,omitempty"`
+	Communities []string `yaml:"communities,omitempty"`
+	// Address ranges in CIDR notation
+	Networks []string `yaml:"networks,omitempty"`
+}
+
+type ServerFilter struct {
+	Include *ServerFilterRule `yaml:"include,omitempty"`
+	Exclude *ServerFilterRule `yaml:"exclude,omitempty"`
+}
+
+// Compile a list of regexes
+func compileRegexes(regexes []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+
+	for _, regex := range regexes {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server filter regex %q: %v", regex, err)
+		}
+
+		compiled = append(compiled, re)
+	}
+
+	return compiled, nil
+}
+
+// Compile a server filter rule, nil stays nil
+func compileServerFilterRule(rule *ServerFilterRule) (*server.FilterRule, error) {
+	var err error
+
+	if rule == nil {
+		return nil, nil
+	}
+
+	compiled := server.FilterRule{
+		GameTypes:   rule.GameTypes,
+		Communities: rule.Communities,
+	}
+
+	if compiled.Names, err = compileRegexes(rule.Names); err != nil {
+		return nil, err
+	}
+
+	if compiled.Versions, err = compileRegexes(rule.Versions); err != nil {
+		return nil, err
+	}
+
+	for _, network := range rule.Networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server filter network %q: %v", network, err)
+		}
+
+		compiled.Networks = append(compiled.Networks, prefix.Masked())
+	}
+
+	return &compiled, nil
+}
+
+// Compile a server filter, nil stays nil
+func compileServerFilter(filter *ServerFilter) (*server.Filter, error) {
+	var err error
+
+	if filter == nil {
+		return nil, nil
+	}
+
+	compiled := server.Filter{}
+
+	if compiled.Include, err = compileServerFilterRule(filter.Include); err != nil {
+		return nil, err
+	}
+
+	if compiled.Exclude, err = compileServerFilterRule(filter.Exclude); err != nil {
+		return nil, err
+	}
+
+	return &compiled, nil
+}
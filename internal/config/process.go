@@ -1,69 +1,59 @@
 package config
 
 import (
-	"fmt"
+	"log"
+	"slices"
+	"time"
 
 	twecon "github.com/theobori/teeworlds-econ"
 	econ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+	gameserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/game_server"
 	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
-	mhttp "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/http"
+	mgame "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/game"
 	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
-	mudp "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/udp"
+	// Registers the http and udp master server protocols
+	_ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/http"
+	_ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/udp"
 )
 
-// Function prototype
-type getConfigMasterServerFunc func(m *MasterServer) (masterserver.MasterServer, error)
-
 var (
-	// Master server configuration error
-	ErrMasterServerConfig = fmt.Errorf("missing master server configuration")
-
-	// Master server configuration protocol
-	MasterServerConfigProtocol = map[string]getConfigMasterServerFunc{
-		"http": processMasterServerHTTP,
-		"udp":  processMasterServerUDP,
-	}
+	// Default cooldown in seconds between each game server refresh
+	GameServerDefaultRefreshCooldown uint = 10
 )
 
-// Return a Teeworlds HTTP master server controller from the configuration
-func processMasterServerHTTP(m *MasterServer) (masterserver.MasterServer, error) {
-	if m == nil {
-		return nil, ErrMasterServerConfig
-	}
-
-	masterServer := mhttp.NewMasterServer(m.URL)
-
-	return masterServer, nil
-}
-
-// Return a Teeworlds UDP master server controller from the configuration
-func processMasterServerUDP(m *MasterServer) (masterserver.MasterServer, error) {
-	if m == nil {
-		return nil, ErrMasterServerConfig
+// Return a master server entry from its configuration. If the master server
+// is unreachable, it is returned anyway to be retried by the refreshes,
+// unless `strict` is true.
+func newMasterServerEntry(masterServerConfig MasterServer, strict bool) (*masterservers.MasterServerManagerEntry, error) {
+	filter, err := compileServerFilter(masterServerConfig.Filter)
+	if err != nil {
+		return nil, err
 	}
 
-	masterServer := mudp.NewMasterServer(m.Host, m.Port)
-
-	err := masterServer.Connect()
+	masterServer, err := masterservers.NewProtocolMasterServer(
+		masterServerConfig.Protocol,
+		masterServerConfig.Node,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	return masterServer, nil
-}
-
-func processMasterServer(
-	msm *masterservers.MasterServerManager,
-	masterServerConfig MasterServer,
-) error {
-	f, found := MasterServerConfigProtocol[masterServerConfig.Protocol]
-	if !found {
-		return fmt.Errorf("invalid master server protocol")
-	}
+	if connecter, ok := masterServer.(masterserver.Connecter); ok {
+		err := connecter.Connect()
+		if err != nil && strict {
+			return nil, err
+		}
 
-	masterServer, err := f(&masterServerConfig)
-	if err != nil {
-		return err
+		if err != nil {
+			metadata := masterServer.Metadata()
+
+			log.Printf(
+				"master server %s with protocol %s is unreachable, retrying in background: %v",
+				metadata.Address,
+				metadata.Protocol,
+				err,
+			)
+		}
 	}
 
 	entry := masterservers.NewMasterServerManagerEntry(
@@ -71,35 +61,45 @@ func processMasterServer(
 		masterServerConfig.RefreshCooldown,
 	)
 
-	err = msm.Register(*entry)
-	if err != nil {
-		return err
-	}
+	entry.Filter = filter
+	entry.StaleAfter = time.Duration(masterServerConfig.StaleAfter) * time.Second
 
-	return nil
+	return entry, nil
 }
 
-// Process the Teeworlds master servers depending of its configuration (protocol),
-// it registers them on the manager `msm`
-func processMasterServers(
-	msm *masterservers.MasterServerManager,
-	masterServerConfigs []MasterServer,
-) error {
-	if msm == nil {
-		return ErrMasterServerConfig
+// Return a Teeworlds game server entry from its configuration,
+// it is polled directly and scheduled like a master server
+func newGameServerEntry(gameServerConfig GameServer) (*masterservers.MasterServerManagerEntry, error) {
+	protocol, err := gameserver.ParseProtocol(gameServerConfig.Protocol)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, masterServerConfig := range masterServerConfigs {
-		err := processMasterServer(msm, masterServerConfig)
-		if err != nil {
-			return err
-		}
+	refreshCooldown := gameServerConfig.RefreshCooldown
+	if refreshCooldown == 0 {
+		refreshCooldown = GameServerDefaultRefreshCooldown
 	}
 
-	return nil
+	masterServer := mgame.NewMasterServer(
+		gameServerConfig.Host,
+		gameServerConfig.Port,
+		protocol,
+	)
+
+	entry := masterservers.NewMasterServerManagerEntry(
+		masterServer,
+		refreshCooldown,
+	)
+
+	entry.StaleAfter = time.Duration(gameServerConfig.StaleAfter) * time.Second
+
+	return entry, nil
 }
 
-func processEconServer(em *econ.EconManager, econConfig EconServer) error {
+// Return an econ client from its configuration. It is returned
+// disconnected, its supervisor establishes the first connection, unless
+// `strict` is true and then an unreachable econ server is an error.
+func newEcon(econConfig EconServer, strict bool) (*twecon.Econ, error) {
 	c := twecon.EconConfig{
 		Host:     econConfig.Host,
 		Port:     econConfig.Port,
@@ -108,40 +108,93 @@ func processEconServer(em *econ.EconManager, econConfig EconServer) error {
 
 	e := twecon.NewEcon(&c)
 
-	if err := e.Connect(); err != nil {
-		return err
+	if !strict {
+		return e, nil
 	}
 
-	if r, err := e.Authenticate(); err != nil || !r.State {
-		return fmt.Errorf("error: %v, response: %v", err, r)
+	if err := econ.Connect(e); err != nil {
+		return nil, err
 	}
 
-	if err := em.Register(e); err != nil {
-		return err
+	return e, nil
+}
+
+// Add `events` to `base`, replacing the events with the same name
+func mergeEconEvents(base []EconEvent, events []EconEvent) []EconEvent {
+	var merged []EconEvent
+
+	for _, event := range base {
+		if !slices.ContainsFunc(events, func(e EconEvent) bool { return e.Name == event.Name }) {
+			merged = append(merged, event)
+		}
 	}
 
-	return nil
+	return append(merged, events...)
 }
 
-func processEconServers(em *econ.EconManager, econConfigs []EconServer) error {
-	for _, econConfig := range econConfigs {
-		err := processEconServer(em, econConfig)
-		if err != nil {
-			return err
+// Return the econ servers configuration with their effective events,
+// built from the built-in, global and per server events
+func resolveEconServers(c Config) []EconServer {
+	var econServers []EconServer
+
+	for _, econServer := range c.Servers.Econ {
+		var events []EconEvent
+
+		defaultEvents := true
+
+		if c.DefaultEvents != nil {
+			defaultEvents = *c.DefaultEvents
 		}
+
+		if econServer.DefaultEvents != nil {
+			defaultEvents = *econServer.DefaultEvents
+		}
+
+		if defaultEvents {
+			for _, event := range econ.EconEvents {
+				events = append(events, EconEvent{Name: event.Name, Regex: event.Regex})
+			}
+		}
+
+		events = mergeEconEvents(events, c.Events)
+		events = mergeEconEvents(events, econServer.Events)
+
+		econServer.Events = events
+		econServer.DefaultEvents = nil
+
+		if econServer.PlayerStats == nil {
+			econServer.PlayerStats = c.PlayerStats
+		}
+
+		econServers = append(econServers, econServer)
 	}
 
-	return nil
+	return econServers
 }
 
-func ProcessConfig(em *econ.EconManager, msm *masterservers.MasterServerManager, c Config) error {
-	if err := processEconServers(em, c.Servers.Econ); err != nil {
-		return err
+// Convert the player statistics configuration for the econ manager
+func playerStatsOptions(playerStats *PlayerStats) econ.PlayerStatsOptions {
+	if playerStats == nil {
+		return econ.PlayerStatsOptions{}
+	}
+
+	return econ.PlayerStatsOptions{
+		Enabled:           playerStats.Enabled,
+		InactivityTimeout: time.Duration(playerStats.InactivityTimeout) * time.Minute,
 	}
+}
+
+// Convert the econ events configuration for the econ manager
+func econEventEntries(events []EconEvent) []econ.EconEventEntry {
+	var entries []econ.EconEventEntry
 
-	if err := processMasterServers(msm, c.Servers.Master); err != nil {
-		return err
+	for _, event := range events {
+		entries = append(entries, econ.EconEventEntry{
+			Name:         event.Name,
+			Regex:        event.Regex,
+			MaxLabelSets: event.MaxLabelSets,
+		})
 	}
 
-	return nil
+	return entries
 }
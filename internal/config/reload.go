@@ -0,0 +1,380 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	twecon "github.com/theobori/teeworlds-econ"
+	econ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+)
+
+// Configuration entry registered on a manager, with its manager key
+type reloadEntry[C any, K comparable] struct {
+	// Entry configuration
+	Config C
+	// Manager key
+	Key K
+}
+
+// Split the registered entries depending if their configuration is still
+// in `next`, and return the configurations of `next` that are not registered yet
+func diffEntries[C any, K comparable](
+	current []reloadEntry[C, K],
+	next []C,
+) (kept []reloadEntry[C, K], removed []reloadEntry[C, K], added []C) {
+	matched := make([]bool, len(next))
+
+	for _, entry := range current {
+		found := false
+
+		for i, c := range next {
+			if !matched[i] && reflect.DeepEqual(entry.Config, c) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+
+		if found {
+			kept = append(kept, entry)
+		} else {
+			removed = append(removed, entry)
+		}
+	}
+
+	for i, c := range next {
+		if !matched[i] {
+			added = append(added, c)
+		}
+	}
+
+	return kept, removed, added
+}
+
+// Status of the last configuration reload
+type ReloadStatus struct {
+	// Whether the last reload succeeded
+	Successful bool
+	// Time of the last successful reload
+	LastSuccess time.Time
+}
+
+// Configuration reloader, it loads the YAML configuration file and
+// applies the difference with the running one on the managers
+type Reloader struct {
+	// Context used to start the refresh goroutines
+	ctx context.Context
+	// Configuration file path
+	filename string
+	// Teeworlds econ servers manager
+	em *econ.EconManager
+	// Teeworlds master servers manager
+	msm *masterservers.MasterServerManager
+	// Registered master servers
+	masterServers []reloadEntry[MasterServer, masterserver.MasterServerMetadata]
+	// Registered game servers
+	gameServers []reloadEntry[GameServer, masterserver.MasterServerMetadata]
+	// Registered econ servers
+	econServers []reloadEntry[EconServer, econ.EconMananagerKey]
+	// Checksum of the last loaded file content
+	checksum [sha256.Size]byte
+	// Running configuration, read on every scrape
+	config atomic.Pointer[Config]
+	// Last reload status
+	status ReloadStatus
+	// Mutex serializing the reloads and protecting `status`
+	mu sync.Mutex
+}
+
+// Create a new configuration reloader
+func NewReloader(
+	ctx context.Context,
+	filename string,
+	em *econ.EconManager,
+	msm *masterservers.MasterServerManager,
+) *Reloader {
+	return &Reloader{
+		ctx:      ctx,
+		filename: filename,
+		em:       em,
+		msm:      msm,
+	}
+}
+
+// Get the running server metrics configuration
+func (r *Reloader) ServerMetrics() ServerMetrics {
+	c := r.config.Load()
+	if c == nil {
+		return ServerMetrics{}
+	}
+
+	return c.ServerMetrics
+}
+
+// Get the running player distribution configuration
+func (r *Reloader) PlayerDistribution() PlayerDistribution {
+	c := r.config.Load()
+	if c == nil {
+		return PlayerDistribution{}
+	}
+
+	return c.PlayerDistribution
+}
+
+// Get the running master server refresh duration histogram configuration
+func (r *Reloader) RequestDuration() RequestDuration {
+	c := r.config.Load()
+	if c == nil {
+		return RequestDuration{}
+	}
+
+	return c.RequestDuration
+}
+
+// Get the last reload status
+func (r *Reloader) Status() ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+// Close the resources created for entries that will not be registered
+func discard(
+	masterServerEntries []*masterservers.MasterServerManagerEntry,
+	econs []*twecon.Econ,
+) {
+	for _, entry := range masterServerEntries {
+		disconnecter, ok := entry.MasterServer.(masterserver.Disconnecter)
+		if ok {
+			_ = disconnecter.Disconnect()
+		}
+	}
+
+	for _, e := range econs {
+		_ = e.Disconnect()
+	}
+}
+
+// Apply a configuration on the managers. Every new entry is created
+// before modifying the managers, so a failure keeps the running configuration.
+// Unreachable servers are registered in a degraded state, unless `strict` is true.
+func (r *Reloader) apply(c Config, strict bool) error {
+	var masterServerEntries []*masterservers.MasterServerManagerEntry
+	var gameServerEntries []*masterservers.MasterServerManagerEntry
+	var econs []*twecon.Econ
+
+	keptMasterServers, removedMasterServers, addedMasterServers := diffEntries(r.masterServers, c.Servers.Master)
+	keptGameServers, removedGameServers, addedGameServers := diffEntries(r.gameServers, c.Servers.Game)
+	keptEconServers, removedEconServers, addedEconServers := diffEntries(r.econServers, resolveEconServers(c))
+
+	filter, err := compileServerFilter(c.Filter)
+	if err != nil {
+		return err
+	}
+
+	for _, masterServerConfig := range addedMasterServers {
+		entry, err := newMasterServerEntry(masterServerConfig, strict)
+		if err != nil {
+			discard(append(masterServerEntries, gameServerEntries...), econs)
+			return err
+		}
+
+		masterServerEntries = append(masterServerEntries, entry)
+	}
+
+	for _, gameServerConfig := range addedGameServers {
+		entry, err := newGameServerEntry(gameServerConfig)
+		if err != nil {
+			discard(append(masterServerEntries, gameServerEntries...), econs)
+			return err
+		}
+
+		gameServerEntries = append(gameServerEntries, entry)
+	}
+
+	for _, econConfig := range addedEconServers {
+		e, err := newEcon(econConfig, strict)
+		if err != nil {
+			discard(append(masterServerEntries, gameServerEntries...), econs)
+			return err
+		}
+
+		econs = append(econs, e)
+	}
+
+	econEntries := make([]*econ.EconMananagerEntry, 0, len(econs))
+
+	for i, e := range econs {
+		econEntries = append(econEntries, econ.NewEconManagerEntry(
+			e,
+			econEventEntries(addedEconServers[i].Events),
+			playerStatsOptions(addedEconServers[i].PlayerStats),
+		))
+	}
+
+	// Every entry is built, the managers are swapped in one step each.
+	// A re-configured entry may have the same key, it is removed first.
+	var removedKeys []masterserver.MasterServerMetadata
+
+	for _, entry := range removedMasterServers {
+		removedKeys = append(removedKeys, entry.Key)
+	}
+
+	for _, entry := range removedGameServers {
+		removedKeys = append(removedKeys, entry.Key)
+	}
+
+	var removedEconKeys []econ.EconMananagerKey
+
+	for _, entry := range removedEconServers {
+		removedEconKeys = append(removedEconKeys, entry.Key)
+	}
+
+	err = r.msm.Replace(
+		removedKeys,
+		append(masterServerEntries, gameServerEntries...),
+		filter,
+	)
+	if err != nil {
+		discard(append(masterServerEntries, gameServerEntries...), econs)
+		return err
+	}
+
+	// It cannot fail, the econ entries have been built from non-nil clients
+	_ = r.em.Replace(removedEconKeys, econEntries)
+
+	for i, entry := range masterServerEntries {
+		keptMasterServers = append(keptMasterServers, reloadEntry[MasterServer, masterserver.MasterServerMetadata]{
+			Config: addedMasterServers[i],
+			Key:    entry.MasterServer.Metadata(),
+		})
+	}
+
+	for i, entry := range gameServerEntries {
+		keptGameServers = append(keptGameServers, reloadEntry[GameServer, masterserver.MasterServerMetadata]{
+			Config: addedGameServers[i],
+			Key:    entry.MasterServer.Metadata(),
+		})
+	}
+
+	for i, e := range econs {
+		c := e.Config()
+
+		keptEconServers = append(keptEconServers, reloadEntry[EconServer, econ.EconMananagerKey]{
+			Config: addedEconServers[i],
+			Key:    econ.EconMananagerKey{Host: c.Host, Port: c.Port},
+		})
+	}
+
+	r.masterServers = keptMasterServers
+	r.gameServers = keptGameServers
+	r.econServers = keptEconServers
+
+	r.config.Store(&c)
+
+	// Only the new entries start refreshing and handling events
+	r.msm.StartRefresh(r.ctx)
+	r.em.Start(r.ctx)
+
+	return nil
+}
+
+// Parse the configuration file content then apply it
+func (r *Reloader) reload(data []byte, strict bool) error {
+	c, err := ConfigFromData(data)
+	if err != nil {
+		return err
+	}
+
+	return r.apply(*c, strict)
+}
+
+// Load the configuration file, on failure the running configuration is kept.
+// If `strict` is true, an unreachable server makes the loading fail.
+func (r *Reloader) Load(strict bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.filename)
+	if err == nil {
+		r.checksum = sha256.Sum256(data)
+		err = r.reload(data, strict)
+	}
+
+	r.status.Successful = err == nil
+
+	if err != nil {
+		return err
+	}
+
+	r.status.LastSuccess = time.Now()
+
+	return nil
+}
+
+// Reload the configuration file, on failure the running configuration is kept
+func (r *Reloader) Reload() error {
+	return r.Load(false)
+}
+
+// Check if the configuration file content changed since the last reload
+func (r *Reloader) changed() bool {
+	data, err := os.ReadFile(r.filename)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sha256.Sum256(data) != r.checksum
+}
+
+// Reload the configuration every time the file changes,
+// it checks the file every `interval` until the context is done
+func (r *Reloader) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !r.changed() {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Printf("could not reload the configuration: %v", err)
+		}
+	}
+}
+
+// HTTP handler reloading the configuration on a POST request
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.Reload(); err != nil {
+		http.Error(w, "failed to reload the configuration: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
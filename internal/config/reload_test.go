@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	econ "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/econ"
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+)
+
+func TestDiffEntries(t *testing.T) {
+	current := []reloadEntry[GameServer, string]{
+		{Config: GameServer{Host: "a", Port: 8303}, Key: "a"},
+		{Config: GameServer{Host: "b", Port: 8303}, Key: "b"},
+	}
+
+	next := []GameServer{
+		{Host: "a", Port: 8303},
+		{Host: "b", Port: 8303, RefreshCooldown: 5},
+		{Host: "c", Port: 8303},
+	}
+
+	kept, removed, added := diffEntries(current, next)
+
+	if len(kept) != 1 || kept[0].Key != "a" {
+		t.Errorf("expected to keep a, got %v", kept)
+	}
+
+	if len(removed) != 1 || removed[0].Key != "b" {
+		t.Errorf("expected to remove b, got %v", removed)
+	}
+
+	if len(added) != 2 {
+		t.Errorf("expected two added entries, got %v", added)
+	}
+}
+
+func TestReloadFailureKeepsRunningConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	msm := masterservers.NewMasterServerManager()
+	r := NewReloader(ctx, filename, econ.NewEconManager(), msm)
+
+	defer func() { _ = msm.Shutdown(context.Background()) }()
+
+	running := []byte(`
+servers:
+  game:
+    - host: 127.0.0.1
+      port: 8303
+      refresh_cooldown: 3600
+`)
+
+	if err := os.WriteFile(filename, running, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Load(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The game server is replaced, then the master server cannot be built
+	failing := []byte(`
+servers:
+  game:
+    - host: 127.0.0.1
+      port: 8304
+  master:
+    - protocol: http
+      url: https://servers.example.com/servers.json
+      tls:
+        ca_file: /missing/ca.pem
+`)
+
+	if err := os.WriteFile(filename, failing, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected a reload error")
+	}
+
+	masterServers := msm.MasterServers()
+	if len(masterServers) != 1 || (*masterServers[0]).Metadata().Address != "127.0.0.1:8303" {
+		t.Errorf("the running game server must be kept")
+	}
+
+	if len(r.gameServers) != 1 || r.gameServers[0].Config.Port != 8303 {
+		t.Errorf("the running configuration must be kept, got %v", r.gameServers)
+	}
+}
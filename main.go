@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -19,14 +25,17 @@ func main() {
 	configPath := flag.String("config-path", "./config.yml", "Teeworlds configuration YAML file path")
 	port := flag.Uint("port", 8080, "Prometheus exporter port")
 	endpoint := flag.String("endpoint", "/metrics", "Prometheus exporter HTTP endpoint")
+	probeEndpoint := flag.String("probe-endpoint", "/probe", "Teeworlds game server probe HTTP endpoint")
+	configWatchInterval := flag.Duration("config-watch-interval", 10*time.Second, "Interval between each configuration file change check, 0 disables it")
+	strictStartup := flag.Bool("strict-startup", false, "Exit at startup if an econ or master server is unreachable")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "Maximum duration of the graceful shutdown")
+	mergeServers := flag.Bool("merge-servers", false, "Export the game servers listed by several master servers once")
 
 	flag.Parse()
 
-	// Get configuration as Golang struct
-	c, err := config.ConfigFromFile(*configPath)
-	if err != nil {
-		log.Fatalln(err)
-	}
+	// Root context, cancelled on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Master server manager
 	msm := masterservers.NewMasterServerManager()
@@ -34,29 +43,50 @@ func main() {
 	// Econ server manager
 	em := econ.NewEconManager()
 
-	// Process and parse the configuration
-	if err := config.ProcessConfig(em, msm, *c); err != nil {
-		log.Fatalln(err)
-	}
+	// Load the configuration, then start refreshing the master servers
+	// and handling the econ events
+	reloader := config.NewReloader(ctx, *configPath, em, msm)
 
-	// Start refreshing the master servers
-	msm.StartRefresh()
+	// The exporter records the refresh durations from the first refresh
+	e := exporter.NewExporter(msm, em, reloader)
+	e.SetMergeServers(*mergeServers)
+	msm.SetRefreshObserver(e.ObserveRefresh)
+	msm.SetRemovalObserver(e.ForgetMasterServer)
 
-	// Register the events for metrics
-	if err := em.RegisterEconEvents(); err != nil {
+	if err := reloader.Load(*strictStartup); err != nil {
 		log.Fatalln(err)
 	}
 
-	// Start handling events
-	if err := em.StartHandle(); err != nil {
-		log.Fatalln(err)
+	// Reload the configuration on SIGHUP
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			}
+
+			if err := reloader.Reload(); err != nil {
+				log.Printf("could not reload the configuration: %v", err)
+			}
+		}
+	}()
+
+	// Reload the configuration when the file changes
+	if *configWatchInterval > 0 {
+		go reloader.Watch(*configWatchInterval)
 	}
 
 	// Register the exporter
-	exporter := exporter.NewExporter(msm, em)
-	prometheus.MustRegister(exporter)
+	prometheus.MustRegister(e)
+	prometheus.MustRegister(exporter.NewEconLabelsExporter(em))
 
 	http.Handle(*endpoint, promhttp.Handler())
+	http.HandleFunc(*probeEndpoint, exporter.ProbeHandler)
+	http.Handle("/-/reload", reloader)
 	http.HandleFunc(
 		"/",
 		func(w http.ResponseWriter, r *http.Request) {
@@ -66,6 +96,7 @@ func main() {
              <body>
              <h1>Teeworlds Exporter</h1>
              <p><a href='` + *endpoint + `'>Metrics</a></p>
+             <p><a href='` + *probeEndpoint + `?target=localhost:8303&protocol=0.7'>Probe localhost:8303</a></p>
              </body>
              </html>`),
 			)
@@ -74,8 +105,34 @@ func main() {
 
 	log.Printf("Exposing metrics via HTTP at endpoint %s on port %d", *endpoint, *port)
 
-	pattern := fmt.Sprintf(":%d", *port)
-	err = http.ListenAndServe(pattern, nil)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", *port)}
+
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err)
+		}
+	}()
+
+	<-ctx.Done()
 
-	log.Fatalln(err)
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting scrapes and drain the pending ones
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println(err)
+	}
+
+	// Stop refreshing the master servers and close their connections
+	if err := msm.Shutdown(shutdownCtx); err != nil {
+		log.Println(err)
+	}
+
+	// Close the econ sessions
+	if err := em.Close(shutdownCtx); err != nil {
+		log.Println(err)
+	}
 }
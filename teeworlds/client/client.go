@@ -15,8 +15,16 @@ type Client struct {
 	Skin     ClientSkin `json:"skin"`
 	Afk      bool       `json:"afk"`
 	Team     int        `json:"team"`
+	// Whether the client is a bot, only sent with the Teeworlds 0.7 flags
+	IsBot bool `json:"-"`
 }
 
+const (
+	// Teeworlds 0.7 server info client flags, a bot is still a player
+	clientFlagSpectator = 1
+	clientFlagBot       = 2
+)
+
 type ClientSkin struct {
 	Name string `json:"name"`
 }
@@ -32,10 +40,11 @@ func FromUDPFields(other *browser.PlayerInfo) (*Client, error) {
 		Clan:     other.Clan,
 		Country:  other.Country,
 		Score:    other.Score,
-		IsPlayer: other.Type == 0,
+		IsPlayer: other.Type&clientFlagSpectator == 0,
 		Skin:     ClientSkin{Name: ""},
 		Afk:      false,
 		Team:     0,
+		IsBot:    other.Type&clientFlagBot != 0,
 	}
 
 	return &client, nil
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/jxsl13/twapi/browser"
+)
+
+func TestFromUDPFieldsFlags(t *testing.T) {
+	tests := []struct {
+		playerType int
+		isPlayer   bool
+		isBot      bool
+	}{
+		{0, true, false},
+		{clientFlagSpectator, false, false},
+		{clientFlagBot, true, true},
+		{clientFlagSpectator | clientFlagBot, false, true},
+	}
+
+	for _, test := range tests {
+		client, err := FromUDPFields(&browser.PlayerInfo{Type: test.playerType})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if client.IsPlayer != test.isPlayer || client.IsBot != test.isBot {
+			t.Errorf(
+				"type %d: expected player %v and bot %v, got %+v",
+				test.playerType,
+				test.isPlayer,
+				test.isBot,
+				client,
+			)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	_ "embed"
+	"strconv"
+	"strings"
+)
+
+// Country code of the clients without any country
+const CountryUnknown = "unknown"
+
+var (
+	// Teeworlds country codes, from the game flags index, to ISO 3166-1
+	// alpha-2 codes. They are the ISO 3166-1 numeric codes, along with
+	// the codes of the game (e.g `737` for South Sudan) and its specific
+	// flags mapped to their ISO 3166-2 subdivision (e.g `901` to `GB-ENG`)
+	//go:embed countries.csv
+	countriesCSV string
+
+	// Parsed `countriesCSV`
+	countries = parseCountries(countriesCSV)
+)
+
+// Parse the numeric to alpha-2 codes table
+func parseCountries(data string) map[int]string {
+	ret := make(map[int]string)
+
+	// Skipping the header
+	lines := strings.Split(strings.TrimSpace(data), "\n")[1:]
+
+	for _, line := range lines {
+		numeric, alpha2, found := strings.Cut(line, ",")
+		if !found {
+			continue
+		}
+
+		code, err := strconv.Atoi(numeric)
+		if err != nil {
+			continue
+		}
+
+		ret[code] = alpha2
+	}
+
+	return ret
+}
+
+// Get the ISO 3166-1 alpha-2 code of a Teeworlds country code,
+// `CountryUnknown` for the default flag (-1) or an unknown code
+func CountryCode(country int) string {
+	alpha2, found := countries[country]
+	if !found {
+		return CountryUnknown
+	}
+
+	return alpha2
+}
+
+// Get the ISO 3166-1 alpha-2 code of the client country
+func (c *Client) CountryCode() string {
+	return CountryCode(c.Country)
+}
@@ -0,0 +1,22 @@
+package client
+
+import "testing"
+
+func TestCountryCode(t *testing.T) {
+	tests := map[int]string{
+		276: "DE",
+		250: "FR",
+		840: "US",
+		901: "GB-ENG",
+		728: "SS",
+		737: "SS",
+		-1:  CountryUnknown,
+		999: CountryUnknown,
+	}
+
+	for country, expected := range tests {
+		if got := CountryCode(country); got != expected {
+			t.Errorf("%d: expected %q, got %q", country, expected, got)
+		}
+	}
+}
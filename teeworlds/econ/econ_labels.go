@@ -0,0 +1,123 @@
+package econ
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Default maximum number of label sets per event
+	DefaultMaxLabelSets uint = 100
+)
+
+// Count of an event for a set of label values
+type EconLabelSet struct {
+	// Values of the named capture groups, in the `Labels` order
+	Values []string
+	// Number of events
+	Count uint
+}
+
+// Counts of an event labeled by the values of its regex named capture groups
+type EconLabeledMetrics struct {
+	// Named capture group names
+	Labels []string
+	// Label sets per key, see `labelSetKey`
+	LabelSets map[string]EconLabelSet
+	// Number of events dropped because of the label sets limit
+	Dropped uint
+}
+
+// Get the key of label values, every value is prefixed with its length
+// so two different label sets cannot share the same key
+func labelSetKey(values []string) string {
+	var b strings.Builder
+
+	for _, value := range values {
+		b.WriteString(strconv.Itoa(len(value)))
+		b.WriteByte(':')
+		b.WriteString(value)
+	}
+
+	return b.String()
+}
+
+// Get the named capture group names of a regex
+func NamedGroups(re *regexp.Regexp) []string {
+	var names []string
+
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Create the labeled metrics of an event, nil if it has no named capture group
+func newEconLabeledMetrics(event EconEventEntry) *EconLabeledMetrics {
+	re, err := regexp.Compile(event.Regex)
+	if err != nil {
+		return nil
+	}
+
+	labels := NamedGroups(re)
+	if len(labels) == 0 {
+		return nil
+	}
+
+	return &EconLabeledMetrics{
+		Labels:    labels,
+		LabelSets: make(map[string]EconLabelSet),
+	}
+}
+
+// Count an event payload with the values of the named capture groups,
+// the payload is dropped if it would exceed `maxLabelSets` label sets.
+// The values are made valid UTF-8, as required for label values.
+func (m *EconLabeledMetrics) add(re *regexp.Regexp, payload string, maxLabelSets uint) {
+	match := re.FindStringSubmatch(payload)
+	if match == nil {
+		return
+	}
+
+	var values []string
+
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			values = append(values, strings.ToValidUTF8(match[i], "�"))
+		}
+	}
+
+	key := labelSetKey(values)
+
+	set, found := m.LabelSets[key]
+	if !found && uint(len(m.LabelSets)) >= maxLabelSets {
+		m.Dropped++
+		return
+	}
+
+	if !found {
+		set.Values = values
+	}
+
+	set.Count++
+	m.LabelSets[key] = set
+}
+
+// Get a copy of the labeled metrics
+func (m *EconLabeledMetrics) copy() EconLabeledMetrics {
+	labelSets := make(map[string]EconLabelSet, len(m.LabelSets))
+
+	for key, set := range m.LabelSets {
+		labelSets[key] = set
+	}
+
+	return EconLabeledMetrics{
+		Labels:    m.Labels,
+		LabelSets: labelSets,
+		Dropped:   m.Dropped,
+	}
+}
@@ -0,0 +1,85 @@
+package econ
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEconLabeledMetricsAdd(t *testing.T) {
+	event := EconEventEntry{
+		Name:  "kill",
+		Regex: `\[game\]: kill killer='\d+:[^']*' victim='\d+:[^']*' weapon=(?P<weapon>-?\d+) special=\d+`,
+	}
+
+	m := newEconLabeledMetrics(event)
+	if m == nil {
+		t.Fatal("expected labeled metrics")
+	}
+
+	if !reflect.DeepEqual(m.Labels, []string{"weapon"}) {
+		t.Fatalf("unexpected labels %v", m.Labels)
+	}
+
+	re := regexp.MustCompile(event.Regex)
+	payload := func(weapon string) string {
+		return "[game]: kill killer='0:nameless tee' victim='1:brainless tee' weapon=" + weapon + " special=0"
+	}
+
+	m.add(re, payload("1"), 2)
+	m.add(re, payload("1"), 2)
+	m.add(re, payload("-1"), 2)
+	// Exceeds the label sets limit
+	m.add(re, payload("3"), 2)
+	// Does not match
+	m.add(re, "[chat]: 0:-2:nameless tee: hello", 2)
+
+	counts := make(map[string]uint)
+	for _, set := range m.LabelSets {
+		counts[set.Values[0]] = set.Count
+	}
+
+	expected := map[string]uint{"1": 2, "-1": 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+
+	if m.Dropped != 1 {
+		t.Errorf("expected 1 dropped event, got %d", m.Dropped)
+	}
+}
+
+func TestNewEconLabeledMetricsWithoutGroup(t *testing.T) {
+	for _, event := range EconEvents {
+		if m := newEconLabeledMetrics(event); m != nil {
+			t.Errorf("%s: expected no labeled metrics", event.Name)
+		}
+	}
+}
+
+func TestEconLabeledMetricsAddValues(t *testing.T) {
+	event := EconEventEntry{
+		Name:  "chat",
+		Regex: `\[chat\]: (?P<a>[^|]*)\|(?P<b>.*)`,
+	}
+
+	m := newEconLabeledMetrics(event)
+	re := regexp.MustCompile(event.Regex)
+
+	// The values must not be merged whatever their content
+	m.add(re, "[chat]: x\xffy|z", 10)
+	m.add(re, "[chat]: x|y\xffz", 10)
+
+	if len(m.LabelSets) != 2 {
+		t.Fatalf("expected two label sets, got %v", m.LabelSets)
+	}
+
+	for _, set := range m.LabelSets {
+		for _, value := range set.Values {
+			if !utf8.ValidString(value) {
+				t.Errorf("invalid UTF-8 label value %q", value)
+			}
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package econ
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"sync"
+	"time"
 
 	twecon "github.com/theobori/teeworlds-econ"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
 )
 
 // Represents an event
@@ -13,10 +17,13 @@ type EconEventEntry struct {
 	Name string
 	// Event regex
 	Regex string
+	// Maximum number of label sets built from the regex named
+	// capture groups, `DefaultMaxLabelSets` if zero
+	MaxLabelSets uint
 }
 
 var (
-	// Econ events used for metrics
+	// Built-in econ events used for metrics
 	EconEvents = []EconEventEntry{
 		// Teeworlds 0.7 events metrics
 		{
@@ -37,14 +44,40 @@ var (
 // Econ metrics storage format. (name, count)
 type EconMetrics map[string]uint
 
+// Econ connection state
+type EconState struct {
+	// Indicating if the econ client is connected and authenticated
+	Up bool
+	// Number of successful reconnections
+	Reconnects uint
+}
+
 // Econ manager map value
 type EconMananagerEntry struct {
 	// Econ client controller
 	Econ *twecon.Econ
+	// Events counted for the econ server
+	Events []EconEventEntry
 	// Metrics associated with a econ server
 	Metrics EconMetrics
+	// Labeled metrics of the events with named capture groups
+	LabeledMetrics map[string]*EconLabeledMetrics
+	// Player statistics options
+	PlayerStats PlayerStatsOptions
+	// Player statistics, collected if enabled by `PlayerStats`
+	Players EconPlayers
 	// Indicating is the econ client is handling events
 	IsHandling bool
+	// Connection state
+	State EconState
+	// Stops the supervisor goroutine
+	cancel context.CancelFunc
+	// Closed when the supervisor goroutine stops
+	done chan struct{}
+	// Receives the echoed ping messages
+	pong chan struct{}
+	// Mutex protecting `Metrics`, `LabeledMetrics`, `Players` and `State`
+	mu sync.Mutex
 }
 
 // Econ manager map key
@@ -56,11 +89,31 @@ type EconMananagerKey struct {
 }
 
 // Create a new EconMananagerEntry struct
-func NewEconManagerEntry(e *twecon.Econ) *EconMananagerEntry {
+func NewEconManagerEntry(
+	e *twecon.Econ,
+	events []EconEventEntry,
+	playerStats PlayerStatsOptions,
+) *EconMananagerEntry {
+	// init metrics with zeros
+	metrics := EconMetrics{}
+	labeledMetrics := make(map[string]*EconLabeledMetrics)
+
+	for _, econEvent := range events {
+		metrics[econEvent.Name] = 0
+
+		if m := newEconLabeledMetrics(econEvent); m != nil {
+			labeledMetrics[econEvent.Name] = m
+		}
+	}
+
 	return &EconMananagerEntry{
-		Econ:       e,
-		Metrics:    EconMetrics{},
-		IsHandling: false,
+		Econ:           e,
+		Events:         events,
+		Metrics:        metrics,
+		LabeledMetrics: labeledMetrics,
+		PlayerStats:    playerStats,
+		Players:        EconPlayers{},
+		IsHandling:     false,
 	}
 }
 
@@ -77,109 +130,317 @@ func NewEconManager() *EconManager {
 	}
 }
 
-// Register a econ client
-func (em *EconManager) Register(e *twecon.Econ) error {
+// Register a econ client with the events to count and the player
+// statistics options, it replaces the one with the same address
+func (em *EconManager) Register(
+	e *twecon.Econ,
+	events []EconEventEntry,
+	playerStats PlayerStatsOptions,
+) error {
 	if e == nil {
 		return fmt.Errorf("nil econ")
 	}
 
 	c := e.Config()
 
-	// init metrics with zeros
-	metrics := EconMetrics{}
+	k := EconMananagerKey{
+		Host: c.Host,
+		Port: c.Port,
+	}
 
-	for _, econEvent := range EconEvents {
-		metrics[econEvent.Name] = 0
+	em.mu.Lock()
+	previous, found := em.econs[k]
+	em.econs[k] = NewEconManagerEntry(e, events, playerStats)
+	em.mu.Unlock()
+
+	// The previous entry is closed without holding the lock,
+	// so the collection is not blocked by an unreachable server
+	if found {
+		_ = closeEntry(context.Background(), previous)
 	}
 
-	entry := EconMananagerEntry{
-		Econ:    e,
-		Metrics: metrics,
+	return nil
+}
+
+// Remove the econ clients `removed` and register the entries `added`
+// in one step, so the collection never sees a partially applied
+// configuration. The replaced entries are closed afterwards,
+// without holding the lock.
+func (em *EconManager) Replace(
+	removed []EconMananagerKey,
+	added []*EconMananagerEntry,
+) error {
+	for _, entry := range added {
+		if entry == nil || entry.Econ == nil {
+			return fmt.Errorf("nil econ")
+		}
 	}
 
-	k := EconMananagerKey{
-		Host: c.Host,
-		Port: c.Port,
+	var closed []*EconMananagerEntry
+
+	em.mu.Lock()
+
+	for _, k := range removed {
+		if entry, found := em.econs[k]; found {
+			closed = append(closed, entry)
+			delete(em.econs, k)
+		}
 	}
 
-	em.econs[k] = &entry
+	for _, entry := range added {
+		c := entry.Econ.Config()
+		k := EconMananagerKey{Host: c.Host, Port: c.Port}
+
+		if previous, found := em.econs[k]; found {
+			closed = append(closed, previous)
+		}
+
+		em.econs[k] = entry
+	}
+
+	em.mu.Unlock()
+
+	for _, entry := range closed {
+		if err := closeEntry(context.Background(), entry); err != nil {
+			debug.Debug(err.Error())
+		}
+	}
 
 	return nil
 }
 
-// Delete a econ client
+// Delete a econ client, it closes its connection
 func (em *EconManager) Delete(k EconMananagerKey) {
+	em.mu.Lock()
+	entry, found := em.econs[k]
 	delete(em.econs, k)
+	em.mu.Unlock()
+
+	if !found {
+		return
+	}
+
+	if err := closeEntry(context.Background(), entry); err != nil {
+		debug.Debug(err.Error())
+	}
 }
 
-// Register a econ events
-func (em *EconManager) RegisterEconEvents() error {
+// Return metrics per econ server
+func (em *EconManager) EconServersMetrics() map[EconMananagerKey]EconMetrics {
+	ret := make(map[EconMananagerKey]EconMetrics)
+
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
-	for _, entry := range em.econs {
-		if entry == nil {
-			continue
+	for k, e := range em.econs {
+		metrics := EconMetrics{}
+
+		e.mu.Lock()
+
+		for name, count := range e.Metrics {
+			metrics[name] = count
 		}
 
-		err := registerMetricEvents(entry.Econ, &entry.Metrics)
-		if err != nil {
-			return err
+		e.mu.Unlock()
+
+		ret[k] = metrics
+	}
+
+	return ret
+}
+
+// Return the labeled metrics per econ server and per event
+func (em *EconManager) EconServersLabeledMetrics() map[EconMananagerKey]map[string]EconLabeledMetrics {
+	ret := make(map[EconMananagerKey]map[string]EconLabeledMetrics)
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	for k, e := range em.econs {
+		labeledMetrics := make(map[string]EconLabeledMetrics)
+
+		e.mu.Lock()
+
+		for name, m := range e.LabeledMetrics {
+			labeledMetrics[name] = m.copy()
 		}
+
+		e.mu.Unlock()
+
+		ret[k] = labeledMetrics
 	}
 
-	return nil
+	return ret
 }
 
-// Return metrics per econ server
-func (em *EconManager) EconServersMetrics() map[EconMananagerKey]EconMetrics {
-	ret := make(map[EconMananagerKey]EconMetrics)
+// Return the player statistics per econ server, the inactive
+// players are evicted first
+func (em *EconManager) EconServersPlayers() map[EconMananagerKey]map[string]PlayerStats {
+	ret := make(map[EconMananagerKey]map[string]PlayerStats)
+	now := time.Now()
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	for k, e := range em.econs {
+		if !e.PlayerStats.Enabled {
+			continue
+		}
+
+		e.mu.Lock()
+
+		e.Players.evict(now, inactivityTimeout(e))
+		ret[k] = e.Players.copy()
+
+		e.mu.Unlock()
+	}
+
+	return ret
+}
+
+// Return the connection state per econ server
+func (em *EconManager) EconServersState() map[EconMananagerKey]EconState {
+	ret := make(map[EconMananagerKey]EconState)
 
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
 	for k, e := range em.econs {
-		ret[k] = e.Metrics
+		e.mu.Lock()
+		ret[k] = e.State
+		e.mu.Unlock()
 	}
 
 	return ret
 }
 
-// Start handling event for every econ client
-func (em *EconManager) StartHandle() error {
+// Start supervising every econ client that is not handling events yet,
+// the supervisor keeps the connection alive and handles the events
+// until `ctx` is done
+func (em *EconManager) Start(ctx context.Context) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	for _, entry := range em.econs {
-		if entry == nil {
+		if entry == nil || entry.Econ == nil || entry.IsHandling {
 			continue
 		}
 
-		if !entry.IsHandling && entry.Econ != nil {
-			entry.IsHandling = true
+		entryCtx, cancel := context.WithCancel(ctx)
+
+		entry.IsHandling = true
+		entry.cancel = cancel
+		entry.done = make(chan struct{})
+		entry.pong = make(chan struct{}, 1)
+
+		go supervise(entryCtx, entry)
+	}
+}
+
+// Stop supervising an econ entry, then log out and close its connection
+// so the game server does not keep a half-open econ session. If `ctx`
+// is done before the supervisor stops, the connection is left to it.
+//
+// The event handling goroutine cannot be stopped, it stays idle
+// once its connection is closed.
+func closeEntry(ctx context.Context, entry *EconMananagerEntry) error {
+	if entry == nil || entry.Econ == nil {
+		return nil
+	}
 
-			go (*entry.Econ).HandleEvents()
+	// The supervisor owns the connection until it stops, its
+	// connection attempts and pings are bounded
+	if entry.cancel != nil {
+		entry.cancel()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 
-	return nil
+	entry.mu.Lock()
+	up := entry.State.Up
+	entry.mu.Unlock()
+
+	// The connection is either missing or broken
+	if !up {
+		_ = entry.Econ.Disconnect()
+		return nil
+	}
+
+	_ = entry.Econ.Send("logout")
+
+	return entry.Econ.Disconnect()
+}
+
+// Close every econ connection, they are removed from the manager
+// then closed concurrently without holding the lock, until `ctx` is done
+func (em *EconManager) Close(ctx context.Context) error {
+	var lastErr error
+	var wg sync.WaitGroup
+	var errMu sync.Mutex
+
+	em.mu.Lock()
+	econs := em.econs
+	em.econs = make(map[EconMananagerKey]*EconMananagerEntry)
+	em.mu.Unlock()
+
+	for _, entry := range econs {
+		wg.Add(1)
+
+		go func(entry *EconMananagerEntry) {
+			defer wg.Done()
+
+			if err := closeEntry(ctx, entry); err != nil {
+				errMu.Lock()
+				lastErr = err
+				errMu.Unlock()
+			}
+		}(entry)
+	}
+
+	wg.Wait()
+
+	return lastErr
 }
 
 // Register the events for metrics
-func registerMetricEvents(e *twecon.Econ, metrics *EconMetrics) error {
-	if e == nil || metrics == nil {
+func registerMetricEvents(entry *EconMananagerEntry) error {
+	if entry == nil || entry.Econ == nil {
 		return fmt.Errorf("nil econ or metrics")
 	}
 
-	for _, event := range EconEvents {
+	for _, eventEntry := range entry.Events {
+		re, err := regexp.Compile(eventEntry.Regex)
+		if err != nil {
+			return err
+		}
+
+		maxLabelSets := eventEntry.MaxLabelSets
+		if maxLabelSets == 0 {
+			maxLabelSets = DefaultMaxLabelSets
+		}
+
 		event := twecon.EconEvent{
-			Name:  event.Name,
-			Regex: event.Regex,
+			Name:  eventEntry.Name,
+			Regex: eventEntry.Regex,
 			Func: func(econ *twecon.Econ, eventPayload string) any {
-				(*metrics)[event.Name]++
+				entry.mu.Lock()
+				defer entry.mu.Unlock()
+
+				entry.Metrics[eventEntry.Name]++
+
+				if m, found := entry.LabeledMetrics[eventEntry.Name]; found {
+					m.add(re, eventPayload, maxLabelSets)
+				}
 
 				return nil
 			},
 		}
 
-		if err := e.EventManager.Register(&event); err != nil {
+		if err := entry.Econ.EventManager.Register(&event); err != nil {
 			return err
 		}
 	}
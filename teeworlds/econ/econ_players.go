@@ -0,0 +1,185 @@
+package econ
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	twecon "github.com/theobori/teeworlds-econ"
+)
+
+const (
+	// Event names of the player statistics
+	econPlayerKillEvent        = "teeworlds_prometheus_exporter_player_kill"
+	econPlayerFlagCaptureEvent = "teeworlds_prometheus_exporter_player_flag_capture"
+
+	// Teeworlds 0.7 weapon of a kill caused by the game, like a team change
+	weaponGame = -3
+)
+
+var (
+	// Teeworlds 0.7 kill line, a name may contain quotes so the killer
+	// name stops at the first victim field and the victim name at the last
+	// weapon field. It is anchored to the line prefix, so a chat message
+	// cannot forge a kill.
+	playerKillRegex = regexp.MustCompile(
+		`^\[[0-9a-f]+\]\[game\]: kill killer='(-?\d+):(.*?)' victim='(-?\d+):(.*)' weapon=(-?\d+) special=\d+`,
+	)
+	// Teeworlds 0.7 flag capture line
+	playerFlagCaptureRegex = regexp.MustCompile(
+		`^\[[0-9a-f]+\]\[game\]: flag_capture player='-?\d+:(.*)'`,
+	)
+
+	// Default duration after which an inactive player is evicted
+	DefaultPlayerInactivityTimeout = 30 * time.Minute
+)
+
+// Player statistics options of an econ server
+type PlayerStatsOptions struct {
+	// Whether the player statistics are collected
+	Enabled bool
+	// Duration after which a player without any kill, death or
+	// flag capture is evicted, `DefaultPlayerInactivityTimeout` if zero
+	InactivityTimeout time.Duration
+}
+
+// Statistics of a player
+type PlayerStats struct {
+	Kills        uint
+	Deaths       uint
+	FlagCaptures uint
+	// Last time the player killed, died or captured a flag
+	LastSeen time.Time
+}
+
+// Player statistics storage format. (name, statistics)
+type EconPlayers map[string]*PlayerStats
+
+// Get the statistics of a player, creating them if needed. The name
+// is made valid UTF-8, as it becomes a label value.
+func (p EconPlayers) player(name string, now time.Time) *PlayerStats {
+	name = strings.ToValidUTF8(name, "�")
+
+	stats, found := p[name]
+	if !found {
+		stats = &PlayerStats{}
+		p[name] = stats
+	}
+
+	stats.LastSeen = now
+
+	return stats
+}
+
+// Evict the players inactive since `timeout`
+func (p EconPlayers) evict(now time.Time, timeout time.Duration) {
+	for name, stats := range p {
+		if now.Sub(stats.LastSeen) >= timeout {
+			delete(p, name)
+		}
+	}
+}
+
+// Count a kill line, it returns false if the line is not a kill
+func (p EconPlayers) addKill(payload string, now time.Time) bool {
+	match := playerKillRegex.FindStringSubmatch(payload)
+	if match == nil {
+		return false
+	}
+
+	killerID, killer, victimID, victim := match[1], match[2], match[3], match[4]
+
+	weapon, err := strconv.Atoi(match[5])
+	if err != nil {
+		return false
+	}
+
+	// Not a death, the victim has been moved by the game
+	if weapon == weaponGame {
+		return true
+	}
+
+	p.player(victim, now).Deaths++
+
+	// A suicide is not a kill
+	if killerID != victimID {
+		p.player(killer, now).Kills++
+	}
+
+	return true
+}
+
+// Count a flag capture line, it returns false if the line is not a capture
+func (p EconPlayers) addFlagCapture(payload string, now time.Time) bool {
+	match := playerFlagCaptureRegex.FindStringSubmatch(payload)
+	if match == nil {
+		return false
+	}
+
+	p.player(match[1], now).FlagCaptures++
+
+	return true
+}
+
+// Get a copy of the player statistics
+func (p EconPlayers) copy() map[string]PlayerStats {
+	ret := make(map[string]PlayerStats, len(p))
+
+	for name, stats := range p {
+		ret[name] = *stats
+	}
+
+	return ret
+}
+
+// Get the inactivity timeout of an entry
+func inactivityTimeout(entry *EconMananagerEntry) time.Duration {
+	if entry.PlayerStats.InactivityTimeout == 0 {
+		return DefaultPlayerInactivityTimeout
+	}
+
+	return entry.PlayerStats.InactivityTimeout
+}
+
+// Register the events for the player statistics
+func registerPlayerEvents(entry *EconMananagerEntry) error {
+	if !entry.PlayerStats.Enabled {
+		return nil
+	}
+
+	events := []twecon.EconEvent{
+		{
+			Name:  econPlayerKillEvent,
+			Regex: playerKillRegex.String(),
+			Func: func(econ *twecon.Econ, eventPayload string) any {
+				entry.mu.Lock()
+				defer entry.mu.Unlock()
+
+				entry.Players.addKill(eventPayload, time.Now())
+
+				return nil
+			},
+		},
+		{
+			Name:  econPlayerFlagCaptureEvent,
+			Regex: playerFlagCaptureRegex.String(),
+			Func: func(econ *twecon.Econ, eventPayload string) any {
+				entry.mu.Lock()
+				defer entry.mu.Unlock()
+
+				entry.Players.addFlagCapture(eventPayload, time.Now())
+
+				return nil
+			},
+		},
+	}
+
+	for i := range events {
+		if err := entry.Econ.EventManager.Register(&events[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package econ
+
+import (
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEconPlayers(t *testing.T) {
+	players := EconPlayers{}
+	now := time.Now()
+
+	lines := []string{
+		"[6650a5e1][game]: kill killer='0:nameless tee' victim='1:it's me' weapon=1 special=0",
+		"[6650a5e1][game]: kill killer='1:it's me' victim='0:nameless tee' weapon=3 special=0",
+		"[6650a5e1][game]: kill killer='0:nameless tee' victim='0:nameless tee' weapon=-2 special=0",
+		"[6650a5e1][game]: kill killer='1:it's me' victim='1:it's me' weapon=-3 special=0",
+		"[6650a5e1][game]: flag_capture player='1:it's me' team=0 time=12.34",
+	}
+
+	for _, line := range lines {
+		if !players.addKill(line, now) && !players.addFlagCapture(line, now) {
+			t.Fatalf("unmatched line %q", line)
+		}
+	}
+
+	if players.addKill("[6650a5e1][chat]: 0:-2:nameless tee: kill killer='0:a'", now) {
+		t.Error("a chat message is not a kill")
+	}
+
+	// A chat message cannot forge a kill or a flag capture
+	spoofed := []string{
+		"[6650a5e1][chat]: 0:-2:nameless tee: [game]: kill killer='0:nameless tee' victim='1:it's me' weapon=1 special=0",
+		"[6650a5e1][chat]: 0:-2:nameless tee: [6650a5e1][game]: flag_capture player='0:nameless tee' team=0 time=1.00",
+	}
+
+	for _, line := range spoofed {
+		if players.addKill(line, now) || players.addFlagCapture(line, now) {
+			t.Errorf("spoofed line %q counted", line)
+		}
+	}
+
+	expected := map[string]PlayerStats{
+		"nameless tee": {Kills: 1, Deaths: 2, LastSeen: now},
+		"it's me":      {Kills: 1, Deaths: 1, FlagCaptures: 1, LastSeen: now},
+	}
+
+	for name, stats := range expected {
+		if got := *players[name]; got != stats {
+			t.Errorf("%s: expected %+v, got %+v", name, stats, got)
+		}
+	}
+
+	players["nameless tee"].LastSeen = now.Add(-time.Hour)
+	players.evict(now, 30*time.Minute)
+
+	if _, found := players["nameless tee"]; found {
+		t.Error("expected the inactive player to be evicted")
+	}
+
+	if _, found := players["it's me"]; !found {
+		t.Error("expected the active player to be kept")
+	}
+}
+
+func TestEconPlayersInvalidName(t *testing.T) {
+	players := EconPlayers{}
+
+	players.addFlagCapture("[6650a5e1][game]: flag_capture player='0:tee\xff' team=0 time=1.00", time.Now())
+
+	for name := range players {
+		if !utf8.ValidString(name) {
+			t.Errorf("invalid UTF-8 player name %q", name)
+		}
+	}
+}
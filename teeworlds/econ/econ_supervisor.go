@@ -0,0 +1,249 @@
+package econ
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	twecon "github.com/theobori/teeworlds-econ"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
+)
+
+const (
+	// Message echoed by the econ server to check the connection
+	econPingMessage = "teeworlds_prometheus_exporter_ping"
+	// Event name receiving the echoed message
+	econPingEvent = "teeworlds_prometheus_exporter_ping"
+	// Maximum duration to wait for the echoed message
+	econPingTimeout = twecon.EconResponseDuration * time.Second
+	// Number of pings sent before considering the connection lost,
+	// a line may be dropped by the econ client while it is busy
+	econPingAttempts = 2
+	// Maximum duration to reach an econ server
+	econDialTimeout = twecon.EconResponseDuration * time.Second
+)
+
+// Exponential backoff between the reconnection attempts
+type Backoff struct {
+	// First delay
+	Initial time.Duration
+	// Maximum delay
+	Max time.Duration
+	// Delay multiplier after each attempt
+	Factor float64
+	// Random part of a delay, between 0 and 1
+	Jitter float64
+}
+
+var (
+	// Backoff used when reconnecting to an econ server
+	DefaultBackoff = Backoff{
+		Initial: time.Second,
+		Max:     time.Minute,
+		Factor:  2,
+		Jitter:  0.2,
+	}
+
+	// Interval between each econ connection check
+	HealthCheckInterval = 10 * time.Second
+)
+
+// Get the delay before the reconnection attempt number `attempt`, starting at 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := float64(b.Initial)
+
+	for i := 0; i < attempt && d < float64(b.Max); i++ {
+		d *= b.Factor
+	}
+
+	d = min(d, float64(b.Max))
+
+	// Spreading the reconnections of the econ clients
+	d *= 1 + b.Jitter*(2*rand.Float64()-1)
+
+	return time.Duration(d)
+}
+
+// Wait for `d`, it returns false if the context is done before
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Register the event receiving the echoed ping messages. The events are
+// used rather than a response wait, because the econ client only listens
+// for a response after sending the request.
+func registerPingEvent(entry *EconMananagerEntry) error {
+	event := twecon.EconEvent{
+		Name:  econPingEvent,
+		Regex: econPingMessage,
+		Func: func(econ *twecon.Econ, eventPayload string) any {
+			select {
+			case entry.pong <- struct{}{}:
+			default:
+			}
+
+			return nil
+		},
+	}
+
+	return entry.Econ.EventManager.Register(&event)
+}
+
+// Check that the econ server still answers, until `ctx` is done
+func ping(ctx context.Context, entry *EconMananagerEntry) error {
+	for attempt := 0; attempt < econPingAttempts; attempt++ {
+		// Dropping a late answer to a previous ping
+		select {
+		case <-entry.pong:
+		default:
+		}
+
+		if err := entry.Econ.Send("echo " + econPingMessage); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-entry.pong:
+			return nil
+		case <-time.After(econPingTimeout):
+		}
+	}
+
+	return fmt.Errorf("timeout waiting for the econ ping")
+}
+
+// Connect and authenticate to the econ server. The econ client dials
+// without any timeout, so the server is reached with a bounded dial first
+// and an unreachable server fails within `econDialTimeout`.
+func Connect(e *twecon.Econ) error {
+	c := e.Config()
+	address := net.JoinHostPort(c.Host, strconv.FormatUint(uint64(c.Port), 10))
+
+	conn, err := net.DialTimeout("tcp", address, econDialTimeout)
+	if err != nil {
+		return err
+	}
+
+	_ = conn.Close()
+
+	if err := e.Connect(); err != nil {
+		return err
+	}
+
+	if r, err := e.Authenticate(); err != nil || !r.State {
+		_ = e.Disconnect()
+
+		return fmt.Errorf("error: %v, response: %v", err, r)
+	}
+
+	return nil
+}
+
+// Set the connection state of an entry
+func setState(entry *EconMananagerEntry, up bool, reconnected bool) {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	entry.State.Up = up
+
+	if reconnected {
+		entry.State.Reconnects++
+	}
+}
+
+// Reconnect to the econ server until it succeeds, waiting between each
+// attempt. It returns false if the context is done before.
+func reconnect(ctx context.Context, entry *EconMananagerEntry) bool {
+	c := entry.Econ.Config()
+
+	for attempt := 0; ; attempt++ {
+		// Closing a possibly broken previous connection
+		_ = entry.Econ.Disconnect()
+
+		err := Connect(entry.Econ)
+		if err == nil {
+			// The entry may have been closed while connecting
+			if ctx.Err() != nil {
+				_ = entry.Econ.Disconnect()
+				return false
+			}
+
+			return true
+		}
+
+		debug.Debug("could not reconnect to the econ server %s:%d: %v", c.Host, c.Port, err)
+
+		if !sleep(ctx, DefaultBackoff.Delay(attempt)) {
+			return false
+		}
+	}
+}
+
+// Goroutine that handles the events of an econ client and keeps
+// its connection alive, reconnecting when it drops
+func supervise(ctx context.Context, entry *EconMananagerEntry) {
+	defer close(entry.done)
+
+	// It keeps handling the events across the reconnections
+	go entry.Econ.HandleEvents()
+
+	if err := registerPingEvent(entry); err != nil {
+		debug.Debug(err.Error())
+	}
+
+	if err := registerMetricEvents(entry); err != nil {
+		debug.Debug(err.Error())
+	}
+
+	if err := registerPlayerEvents(entry); err != nil {
+		debug.Debug(err.Error())
+	}
+
+	wasUp := false
+
+	for {
+		if err := ping(ctx, entry); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			setState(entry, false, false)
+
+			if !reconnect(ctx, entry) {
+				return
+			}
+
+			// Registering the events again for the new connection
+			if err := registerMetricEvents(entry); err != nil {
+				debug.Debug(err.Error())
+			}
+
+			if err := registerPlayerEvents(entry); err != nil {
+				debug.Debug(err.Error())
+			}
+
+			setState(entry, true, wasUp)
+		} else {
+			setState(entry, true, false)
+		}
+
+		wasUp = true
+
+		if !sleep(ctx, HealthCheckInterval) {
+			return
+		}
+	}
+}
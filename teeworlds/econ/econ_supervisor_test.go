@@ -0,0 +1,188 @@
+package econ
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	twecon "github.com/theobori/teeworlds-econ"
+)
+
+// Minimal econ server accepting any password and answering to echo
+func serveEcon(conn net.Conn) {
+	defer conn.Close()
+
+	_, _ = conn.Write([]byte(twecon.EconPasswordMessage + "\n"))
+
+	scanner := bufio.NewScanner(conn)
+
+	if !scanner.Scan() {
+		return
+	}
+
+	_, _ = conn.Write([]byte(twecon.EconAuthSuccessMessage + "\n"))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if message, ok := strings.CutPrefix(line, "echo "); ok {
+			_, _ = conn.Write([]byte("[console]: " + message + "\n"))
+		}
+	}
+}
+
+// Start a minimal econ server, the accepted connections are sent to `conns`
+func listenEcon(t *testing.T, conns chan<- net.Conn) (net.Listener, EconMananagerKey) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			if conns != nil {
+				conns <- conn
+			}
+
+			go serveEcon(conn)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	return listener, EconMananagerKey{Host: "127.0.0.1", Port: uint16(addr.Port)}
+}
+
+// Wait for the econ state of `k` to match `expected`
+func waitState(t *testing.T, em *EconManager, k EconMananagerKey, expected EconState) {
+	deadline := time.Now().Add(econPingAttempts * 2 * econPingTimeout)
+
+	for time.Now().Before(deadline) {
+		if em.EconServersState()[k] == expected {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected the econ state %+v, got %+v", expected, em.EconServersState()[k])
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second, Factor: 2}
+
+	if d := b.Delay(0); d != time.Second {
+		t.Errorf("expected 1s, got %v", d)
+	}
+
+	if d := b.Delay(2); d != 4*time.Second {
+		t.Errorf("expected 4s, got %v", d)
+	}
+
+	if d := b.Delay(10); d != 10*time.Second {
+		t.Errorf("expected 10s, got %v", d)
+	}
+
+	b.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		if d := b.Delay(0); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay %v out of the jitter range", d)
+		}
+	}
+}
+
+func TestSuperviseReconnect(t *testing.T) {
+	defer func(interval time.Duration, backoff Backoff) {
+		HealthCheckInterval = interval
+		DefaultBackoff = backoff
+	}(HealthCheckInterval, DefaultBackoff)
+
+	HealthCheckInterval = 50 * time.Millisecond
+	DefaultBackoff = Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Factor: 2}
+
+	conns := make(chan net.Conn, 3)
+
+	listener, k := listenEcon(t, conns)
+	defer listener.Close()
+
+	e := twecon.NewEcon(&twecon.EconConfig{
+		Host: k.Host,
+		Port: k.Port,
+	})
+
+	if err := Connect(e); err != nil {
+		t.Fatal(err)
+	}
+
+	em := NewEconManager()
+
+	if err := em.Register(e, EconEvents, PlayerStatsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	em.Start(context.Background())
+	defer func() { _ = em.Close(context.Background()) }()
+
+	waitState(t, em, k, EconState{Up: true, Reconnects: 0})
+
+	// Dropping the first connection on the server side, after the
+	// bounded dial that reached the server
+	<-conns
+	(<-conns).Close()
+
+	waitState(t, em, k, EconState{Up: true, Reconnects: 1})
+}
+
+func TestSuperviseFirstConnection(t *testing.T) {
+	listener, k := listenEcon(t, nil)
+	defer listener.Close()
+
+	em := NewEconManager()
+
+	// Never connected, the supervisor establishes the first connection
+	e := twecon.NewEcon(&twecon.EconConfig{Host: k.Host, Port: k.Port})
+
+	if err := em.Register(e, EconEvents, PlayerStatsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	em.Start(context.Background())
+	defer func() { _ = em.Close(context.Background()) }()
+
+	waitState(t, em, k, EconState{Up: true, Reconnects: 0})
+}
+
+func TestCloseWhileReconnecting(t *testing.T) {
+	listener, k := listenEcon(t, nil)
+	// Refusing the connections
+	listener.Close()
+
+	em := NewEconManager()
+	e := twecon.NewEcon(&twecon.EconConfig{Host: k.Host, Port: k.Port})
+
+	if err := em.Register(e, EconEvents, PlayerStatsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	em.Start(context.Background())
+
+	// Letting the supervisor try to connect
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+
+	_ = em.Close(context.Background())
+
+	if elapsed := time.Since(start); elapsed > econDialTimeout {
+		t.Errorf("closing took %v", elapsed)
+	}
+}
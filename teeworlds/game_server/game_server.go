@@ -0,0 +1,98 @@
+package gameserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jxsl13/twapi/browser"
+	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+// Teeworlds game server network protocol
+type Protocol string
+
+const (
+	// Teeworlds 0.6 protocol
+	Protocol06 Protocol = "0.6"
+	// Teeworlds 0.7 protocol
+	Protocol07 Protocol = "0.7"
+	// DDNet extended 0.6 protocol
+	ProtocolDDNet Protocol = "ddnet"
+)
+
+var (
+	// Default protocol used when none is specified
+	DefaultProtocol = Protocol07
+
+	// Default timeout for a server info request
+	DefaultTimeout = 5 * time.Second
+
+	// Invalid protocol error
+	ErrInvalidProtocol = fmt.Errorf("invalid game server protocol")
+)
+
+// Get a `Protocol` from its string representation
+func ParseProtocol(s string) (Protocol, error) {
+	if s == "" {
+		return DefaultProtocol, nil
+	}
+
+	protocol := Protocol(strings.ToLower(s))
+
+	switch protocol {
+	case Protocol06, Protocol07, ProtocolDDNet:
+		return protocol, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidProtocol, s)
+	}
+}
+
+// Get the remaining time before the context deadline
+func timeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return DefaultTimeout
+	}
+
+	return time.Until(deadline)
+}
+
+// Request the server informations with the Teeworlds 0.7 protocol
+func serverInfo07(ctx context.Context, address string) (*twserver.Server, error) {
+	client, err := browser.NewClient(address)
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	client.SetReadTimeout(timeout(ctx))
+
+	serverInfo, err := client.GetServerInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return twserver.FromUDPFields(serverInfo)
+}
+
+// Request the informations of a Teeworlds game server located at `address`
+// with the format 'host:port'
+func ServerInfo(ctx context.Context, address string, protocol Protocol) (*twserver.Server, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch protocol {
+	case Protocol07:
+		return serverInfo07(ctx, address)
+	case Protocol06:
+		return serverInfoLegacy(ctx, address, false)
+	case ProtocolDDNet:
+		return serverInfoLegacy(ctx, address, true)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProtocol, protocol)
+	}
+}
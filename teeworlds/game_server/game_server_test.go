@@ -0,0 +1,129 @@
+package gameserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Build a vanilla server info response with a single client
+func vanillaInfoResponse(token int, name string) []byte {
+	fields := []string{
+		strconv.Itoa(token), "0.6.4", name, "ctf5", "CTF", "1", "1", "16", "1", "16",
+		"nameless tee", "clan", "276", "10", "1",
+	}
+
+	return []byte(legacyHeader + legacySendInfo + strings.Join(fields, "\x00") + "\x00")
+}
+
+func TestParseProtocol(t *testing.T) {
+	protocol, err := ParseProtocol("")
+	if err != nil || protocol != DefaultProtocol {
+		t.Errorf("expected the default protocol, got %q", protocol)
+	}
+
+	protocol, err = ParseProtocol("DDNet")
+	if err != nil || protocol != ProtocolDDNet {
+		t.Errorf("expected %q, got %q", ProtocolDDNet, protocol)
+	}
+
+	if _, err := ParseProtocol("0.5"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseLegacyInfo(t *testing.T) {
+	server, clientsAmount, err := parseLegacyInfo(vanillaInfoResponse(42, "My server"), "127.0.0.1:8303", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clientsAmount != 1 || len(server.Info.Clients) != 1 {
+		t.Errorf("expected one client")
+	}
+
+	if !server.Info.Passworded {
+		t.Error("expected a passworded server")
+	}
+
+	if server.Info.Map.Name != "ctf5" || server.Info.GameType != "CTF" {
+		t.Errorf("invalid server informations: %+v", server.Info)
+	}
+
+	client := server.Info.Clients[0]
+	if client.Name != "nameless tee" || client.Country != 276 || !client.IsPlayer {
+		t.Errorf("invalid client: %+v", client)
+	}
+
+	if _, _, err := parseLegacyInfo([]byte(legacyHeader+legacySendInfo+"42"), "", 42); err == nil {
+		t.Error("expected an error")
+	}
+
+	_, _, err = parseLegacyInfo(vanillaInfoResponse(43, "My server"), "127.0.0.1:8303", 42)
+	if !errors.Is(err, ErrTokenMismatch) {
+		t.Errorf("expected a token mismatch, got %v", err)
+	}
+}
+
+func TestParseLegacyInfoInvalidUTF8(t *testing.T) {
+	// A name truncated in the middle of a multibyte character
+	name := string([]byte("tee \xc3"))
+
+	server, _, err := parseLegacyInfo(vanillaInfoResponse(42, name), "127.0.0.1:8303", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !utf8.ValidString(server.Info.Name) || server.Info.Name != "tee �" {
+		t.Errorf("invalid server name %q", server.Info.Name)
+	}
+}
+
+func TestServerInfoLegacy(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, legacyMaxPacketSize)
+
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		token := int(buf[n-1])
+
+		// A spoofed response to another request is discarded
+		_, _ = conn.WriteTo(vanillaInfoResponse((token+1)%256, "Spoofed server"), addr)
+		_, _ = conn.WriteTo(vanillaInfoResponse(token, "My server"), addr)
+	}()
+
+	server, err := ServerInfo(context.Background(), conn.LocalAddr().String(), Protocol06)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Info.Name != "My server" {
+		t.Errorf("invalid server name %q", server.Info.Name)
+	}
+}
+
+func TestLegacyRequestExtendedToken(t *testing.T) {
+	request, token := legacyRequest(true)
+
+	// The server extends the request token with the two extra header bytes
+	extra := int(request[2])<<8 | int(request[3])
+	expected := int(request[len(request)-1]) | extra<<8
+
+	if token != expected {
+		t.Errorf("expected the token %d, got %d", expected, token)
+	}
+}
@@ -0,0 +1,355 @@
+package gameserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	twclient "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/client"
+	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+const (
+	// Connless packet header used by Teeworlds 0.6
+	legacyHeader = "\xff\xff\xff\xff\xff\xff"
+	// Connless packet header prefix used by DDNet extended packets
+	legacyHeaderExtended = "xe"
+	// Connless packet header size
+	legacyHeaderSize = 6
+
+	// Server info request
+	legacyRequestInfo = "\xff\xff\xff\xffgie3"
+	// Vanilla server info response
+	legacySendInfo = "\xff\xff\xff\xffinf3"
+	// DDNet extended server info response
+	legacySendInfoExtended = "\xff\xff\xff\xffiext"
+	// DDNet extended server info response continuation
+	legacySendInfoExtendedMore = "\xff\xff\xff\xffiex+"
+
+	// Maximum UDP packet size for Teeworlds
+	legacyMaxPacketSize = 1400
+)
+
+var (
+	// Malformed server info response error
+	ErrMalformedResponse = fmt.Errorf("malformed server info response")
+
+	// Server info response to another request error
+	ErrTokenMismatch = fmt.Errorf("server info response token mismatch")
+)
+
+// Reads the null-terminated strings of a server info response
+type unpacker struct {
+	data []byte
+}
+
+// Get the next string, made valid UTF-8 as the server may send
+// truncated multibyte names and they become label values
+func (u *unpacker) nextString() (string, error) {
+	i := bytes.IndexByte(u.data, 0)
+	if i < 0 {
+		return "", ErrMalformedResponse
+	}
+
+	s := strings.ToValidUTF8(string(u.data[:i]), "�")
+	u.data = u.data[i+1:]
+
+	return s, nil
+}
+
+// Get the next integer
+func (u *unpacker) nextInt() (int, error) {
+	s, err := u.nextString()
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, ErrMalformedResponse
+	}
+
+	return i, nil
+}
+
+// Check if there is remaining data
+func (u *unpacker) empty() bool {
+	return len(u.data) == 0
+}
+
+// Create a server info request packet, with the token
+// the server has to send back
+func legacyRequest(extended bool) ([]byte, int) {
+	var packet []byte
+
+	token := rand.Intn(256)
+
+	if extended {
+		// The two extra bytes extend the token, the two last are reserved
+		extra := rand.Intn(1 << 16)
+		token |= extra << 8
+
+		packet = append(packet, legacyHeaderExtended...)
+		packet = append(packet, byte(extra>>8), byte(extra), 0, 0)
+	} else {
+		packet = append(packet, legacyHeader...)
+	}
+
+	packet = append(packet, legacyRequestInfo...)
+	packet = append(packet, byte(token))
+
+	return packet, token
+}
+
+// Check the token of a server info response
+func checkToken(u *unpacker, token int) error {
+	responseToken, err := u.nextInt()
+	if err != nil {
+		return err
+	}
+
+	if responseToken != token {
+		return ErrTokenMismatch
+	}
+
+	return nil
+}
+
+// Parse the clients from a server info response
+func parseLegacyClients(u *unpacker, extended bool) ([]twclient.Client, error) {
+	var clients []twclient.Client
+
+	for !u.empty() {
+		var client twclient.Client
+		var err error
+
+		if client.Name, err = u.nextString(); err != nil {
+			return nil, err
+		}
+
+		if client.Clan, err = u.nextString(); err != nil {
+			return nil, err
+		}
+
+		if client.Country, err = u.nextInt(); err != nil {
+			return nil, err
+		}
+
+		if client.Score, err = u.nextInt(); err != nil {
+			return nil, err
+		}
+
+		isPlayer, err := u.nextInt()
+		if err != nil {
+			return nil, err
+		}
+
+		client.IsPlayer = isPlayer != 0
+
+		// Reserved field
+		if extended {
+			if _, err := u.nextString(); err != nil {
+				return nil, err
+			}
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// Parse a vanilla or DDNet extended server info response to the request
+// with `token`, it returns the server and the number of clients it announces
+func parseLegacyInfo(data []byte, address string, token int) (*twserver.Server, int, error) {
+	var server twserver.Server
+	var err error
+
+	if len(data) < legacyHeaderSize+len(legacySendInfo) {
+		return nil, 0, ErrMalformedResponse
+	}
+
+	data = data[legacyHeaderSize:]
+	header := string(data[:len(legacySendInfo)])
+
+	extended := header == legacySendInfoExtended
+	if !extended && header != legacySendInfo {
+		return nil, 0, ErrMalformedResponse
+	}
+
+	u := unpacker{data: data[len(legacySendInfo):]}
+	info := &server.Info
+
+	if err = checkToken(&u, token); err != nil {
+		return nil, 0, err
+	}
+
+	if info.Version, err = u.nextString(); err != nil {
+		return nil, 0, err
+	}
+
+	if info.Name, err = u.nextString(); err != nil {
+		return nil, 0, err
+	}
+
+	if info.Map.Name, err = u.nextString(); err != nil {
+		return nil, 0, err
+	}
+
+	if extended {
+		// Map CRC
+		if _, err = u.nextString(); err != nil {
+			return nil, 0, err
+		}
+
+		if info.Map.Size, err = u.nextInt(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if info.GameType, err = u.nextString(); err != nil {
+		return nil, 0, err
+	}
+
+	flags, err := u.nextInt()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info.Passworded = (flags & 1) == 1
+
+	// Players amount
+	if _, err = u.nextInt(); err != nil {
+		return nil, 0, err
+	}
+
+	if info.MaxPlayers, err = u.nextInt(); err != nil {
+		return nil, 0, err
+	}
+
+	clientsAmount, err := u.nextInt()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if info.MaxClients, err = u.nextInt(); err != nil {
+		return nil, 0, err
+	}
+
+	// Reserved field
+	if extended {
+		if _, err = u.nextString(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if info.Clients, err = parseLegacyClients(&u, extended); err != nil {
+		return nil, 0, err
+	}
+
+	server.Addresses = []string{twserver.SchemeTeeworlds06 + "://" + address}
+
+	return &server, clientsAmount, nil
+}
+
+// Parse the clients of a DDNet extended server info continuation response
+// to the request with `token`
+func parseLegacyInfoMore(data []byte, token int) ([]twclient.Client, error) {
+	if len(data) < legacyHeaderSize+len(legacySendInfoExtendedMore) {
+		return nil, ErrMalformedResponse
+	}
+
+	data = data[legacyHeaderSize:]
+
+	if string(data[:len(legacySendInfoExtendedMore)]) != legacySendInfoExtendedMore {
+		return nil, ErrMalformedResponse
+	}
+
+	u := unpacker{data: data[len(legacySendInfoExtendedMore):]}
+
+	if err := checkToken(&u, token); err != nil {
+		return nil, err
+	}
+
+	// Packet number and reserved field
+	for i := 0; i < 2; i++ {
+		if _, err := u.nextString(); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseLegacyClients(&u, true)
+}
+
+// Request the server informations with the Teeworlds 0.6 protocol,
+// or the DDNet one if `extended` is true
+func serverInfoLegacy(ctx context.Context, address string, extended bool) (*twserver.Server, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout(ctx))); err != nil {
+		return nil, err
+	}
+
+	request, token := legacyRequest(extended)
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, legacyMaxPacketSize)
+
+	var server *twserver.Server
+	var clientsAmount int
+
+	// Discarding the responses to other requests, until the deadline
+	for server == nil {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		server, clientsAmount, err = parseLegacyInfo(buf[:n], address, token)
+		if errors.Is(err, ErrTokenMismatch) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// DDNet splits the clients across several packets
+	for extended && len(server.Info.Clients) < clientsAmount {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+
+			// Keep the clients received so far
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+
+			return nil, err
+		}
+
+		clients, err := parseLegacyInfoMore(buf[:n], token)
+		if err != nil {
+			continue
+		}
+
+		server.Info.Clients = append(server.Info.Clients, clients...)
+	}
+
+	return server, nil
+}
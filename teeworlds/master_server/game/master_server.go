@@ -0,0 +1,129 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	gameserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/game_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+var (
+	// Master server protocol
+	MasterServerProtocol = "game"
+)
+
+// Game server controller, it polls a single Teeworlds game server
+// directly, without any master server involved
+type MasterServerGame struct {
+	// Game server host
+	host string
+	// Game server port
+	port uint16
+	// Game server network protocol
+	protocol gameserver.Protocol
+	// Teeworlds server informations
+	servers []*twserver.Server
+	// Game server metrics
+	metrics masterserver.MasterServerMetrics
+	// Mutex protecting `servers` and `metrics`
+	mu sync.Mutex
+}
+
+// Create a new MasterServerGame struct
+func NewMasterServer(host string, port uint16, protocol gameserver.Protocol) *MasterServerGame {
+	return &MasterServerGame{
+		host:     host,
+		port:     port,
+		protocol: protocol,
+		servers:  []*twserver.Server{},
+		metrics:  masterserver.MasterServerMetrics{},
+	}
+}
+
+// Get the game server address
+func (ms *MasterServerGame) Address() string {
+	return fmt.Sprintf("%s:%d", ms.host, ms.port)
+}
+
+// Get the game server network protocol
+func (ms *MasterServerGame) Protocol() gameserver.Protocol {
+	return ms.protocol
+}
+
+// Get the master server metadata
+func (ms *MasterServerGame) Metadata() masterserver.MasterServerMetadata {
+	return masterserver.MasterServerMetadata{
+		Protocol: MasterServerProtocol,
+		Address:  ms.Address(),
+	}
+}
+
+// Refresh the Teeworlds server informations with a context
+func (ms *MasterServerGame) RefreshWithContext(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, gameserver.DefaultTimeout)
+	defer cancel()
+
+	start := time.Now()
+
+	server, err := gameserver.ServerInfo(ctx, ms.Address(), ms.protocol)
+
+	elapsed := time.Since(start).Seconds()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.metrics.Up = err == nil
+
+	if err != nil {
+		ms.metrics.FailedRefreshCount++
+
+		return err
+	}
+
+	ms.metrics.RequestTime = elapsed
+	ms.metrics.SuccessRefreshCount++
+	ms.metrics.LastSuccess = time.Now()
+
+	ms.servers = []*twserver.Server{server}
+
+	return nil
+}
+
+// Refresh the Teeworlds server informations
+func (ms *MasterServerGame) Refresh() error {
+	return ms.RefreshWithContext(context.Background())
+}
+
+// Get the Teeworlds server informations
+func (ms *MasterServerGame) Servers() ([]*twserver.Server, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.servers, nil
+}
+
+// Get the game server metrics
+func (ms *MasterServerGame) Metrics() masterserver.MasterServerMetrics {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.metrics
+}
+
+// Get the servers and the metrics at once
+func (ms *MasterServerGame) Snapshot() masterserver.MasterServerSnapshot {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return masterserver.MasterServerSnapshot{
+		Metadata:     ms.Metadata(),
+		Servers:      ms.servers,
+		TotalServers: len(ms.servers),
+		TotalPlayers: masterserver.CountPlayers(ms.servers),
+		Metrics:      ms.metrics,
+	}
+}
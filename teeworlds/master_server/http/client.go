@@ -0,0 +1,124 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTP client options of a master server
+type HTTPClientOptions struct {
+	// Request timeout, `DefaultTimeout` if zero
+	Timeout time.Duration
+	// Proxy url, the environment proxy if empty
+	ProxyURL string
+	// PEM certificates trusted on top of the system ones
+	CAFile string
+	// Whether the server certificate is not verified
+	InsecureSkipVerify bool
+	// User-Agent header, the Go default one if empty
+	UserAgent string
+	// Headers sent with every request
+	Headers map[string]string
+}
+
+// Round tripper setting headers on every request
+type headerTransport struct {
+	// Underlying round tripper
+	base http.RoundTripper
+	// Headers overriding the request ones
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A round tripper must not modify the request
+	req = req.Clone(req.Context())
+
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// Get the certificates pool trusting the system certificates
+// and the ones of `caFile`
+func certPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificate found in %s", caFile)
+	}
+
+	return pool, nil
+}
+
+// Create a HTTP client from its options
+func NewHTTPClient(options HTTPClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if options.ProxyURL != "" {
+		proxyURL, err := url.Parse(options.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %v", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if options.CAFile != "" || options.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: options.InsecureSkipVerify,
+		}
+	}
+
+	if options.CAFile != "" {
+		pool, err := certPool(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+
+	header := make(http.Header)
+
+	for key, value := range options.Headers {
+		header.Set(key, value)
+	}
+
+	if options.UserAgent != "" {
+		header.Set("User-Agent", options.UserAgent)
+	}
+
+	if len(header) > 0 {
+		client.Transport = &headerTransport{
+			base:   transport,
+			header: header,
+		}
+	}
+
+	return client, nil
+}
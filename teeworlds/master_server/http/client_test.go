@@ -0,0 +1,66 @@
+package http
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewHTTPClientHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "exporter/1.0" || r.Header.Get("X-Token") != "secret" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		_, _ = w.Write([]byte(`{"servers": []}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewHTTPClient(HTTPClientOptions{
+		UserAgent: "exporter/1.0",
+		Headers:   map[string]string{"x-token": "secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := NewMasterServer(ts.URL)
+	ms.SetHTTPClient(client)
+
+	if err := ms.RefreshWithoutContext(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewHTTPClientCAFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"servers": []}`))
+	}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+
+	if err := os.WriteFile(caFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewHTTPClient(HTTPClientOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := NewMasterServer(ts.URL)
+	ms.SetHTTPClient(client)
+
+	if err := ms.RefreshWithoutContext(); err != nil {
+		t.Errorf("the private CA must be trusted: %v", err)
+	}
+
+	if _, err := NewHTTPClient(HTTPClientOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected a missing CA file error")
+	}
+}
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"gopkg.in/yaml.v3"
+)
+
+// HTTP master server YAML configuration
+type Config struct {
+	URL string `yaml:"url,omitempty"`
+	// Mirror urls serving the same servers, instead of `url`
+	URLs []string `yaml:"urls,omitempty"`
+	// Order in which the `urls` are tried, failover by default
+	Strategy Strategy `yaml:"strategy,omitempty"`
+	// HTTP request timeout in seconds, `DefaultTimeout` by default
+	Timeout uint `yaml:"timeout,omitempty"`
+	// HTTP proxy url, the environment proxy by default
+	ProxyURL string `yaml:"proxy_url,omitempty"`
+	// HTTPS options
+	TLS *TLSConfig `yaml:"tls,omitempty"`
+	// User-Agent header of the HTTP requests
+	UserAgent string `yaml:"user_agent,omitempty"`
+	// Headers sent with every HTTP request
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Maximum size of a decompressed response body, `DefaultMaxResponseBytes` by default
+	MaxResponseBytes int64 `yaml:"max_response_bytes,omitempty"`
+}
+
+// HTTPS YAML configuration
+type TLSConfig struct {
+	// PEM certificates trusted on top of the system ones
+	CAFile string `yaml:"ca_file,omitempty"`
+	// Whether the server certificate is not verified
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+}
+
+var (
+	// Headers set by the HTTP master servers themselves
+	reservedHeaders = []string{
+		"Accept-Encoding",
+		"If-Modified-Since",
+		"If-None-Match",
+	}
+)
+
+func init() {
+	masterservers.RegisterProtocol(MasterServerProtocol, newFromNode)
+}
+
+// Get the HTTP master server configuration from its YAML node
+func ConfigFromNode(node *yaml.Node) (*Config, error) {
+	var c Config
+
+	if err := node.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Get every url of the configuration
+func (c *Config) Urls() []string {
+	if len(c.URLs) > 0 {
+		return c.URLs
+	}
+
+	return []string{c.URL}
+}
+
+// Check the configuration values that cannot be checked by the YAML decoding
+func (c *Config) Validate() error {
+	if c.URL != "" && len(c.URLs) > 0 {
+		return fmt.Errorf("the master server url and urls are mutually exclusive")
+	}
+
+	for _, u := range c.Urls() {
+		if u == "" {
+			return fmt.Errorf("missing master server url")
+		}
+	}
+
+	if c.Strategy != "" && !slices.Contains(Strategies, c.Strategy) {
+		return fmt.Errorf("invalid master server strategy %q", c.Strategy)
+	}
+
+	if c.MaxResponseBytes < 0 {
+		return fmt.Errorf("negative master server max_response_bytes")
+	}
+
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fmt.Errorf("invalid master server proxy url %q", c.ProxyURL)
+		}
+	}
+
+	for key := range c.Headers {
+		if slices.Contains(reservedHeaders, http.CanonicalHeaderKey(key)) {
+			return fmt.Errorf("the master server header %q cannot be overridden", key)
+		}
+	}
+
+	return nil
+}
+
+// Create a HTTP master server from its configuration
+func NewFromConfig(c *Config) (*MasterServerHTTP, error) {
+	strategy := StrategyFailover
+	if c.Strategy != "" {
+		strategy = c.Strategy
+	}
+
+	masterServer, err := NewMirroredMasterServer(c.Urls(), strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	options := HTTPClientOptions{
+		Timeout:   time.Duration(c.Timeout) * time.Second,
+		ProxyURL:  c.ProxyURL,
+		UserAgent: c.UserAgent,
+		Headers:   c.Headers,
+	}
+
+	if c.TLS != nil {
+		options.CAFile = c.TLS.CAFile
+		options.InsecureSkipVerify = c.TLS.InsecureSkipVerify
+	}
+
+	httpClient, err := NewHTTPClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	masterServer.SetHTTPClient(httpClient)
+
+	if c.MaxResponseBytes > 0 {
+		masterServer.SetMaxResponseBytes(c.MaxResponseBytes)
+	}
+
+	return masterServer, nil
+}
+
+// Create a HTTP master server from its YAML configuration node
+func newFromNode(node *yaml.Node) (masterserver.MasterServer, error) {
+	c, err := ConfigFromNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromConfig(c)
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+var (
+	// Default maximum size of a decompressed response body
+	DefaultMaxResponseBytes int64 = 64 << 20
+
+	// Response body bigger than the maximum response size
+	ErrResponseTooLarge = fmt.Errorf("response body too large")
+)
+
+// Reader failing with `ErrResponseTooLarge` once more than `remaining`
+// bytes are read
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (mr *maxBytesReader) Read(p []byte) (int, error) {
+	if mr.remaining <= 0 {
+		// Checking if the body ends exactly at the limit
+		var probe [1]byte
+
+		n, err := mr.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+
+		return 0, err
+	}
+
+	if int64(len(p)) > mr.remaining {
+		p = p[:mr.remaining]
+	}
+
+	n, err := mr.r.Read(p)
+	mr.remaining -= int64(n)
+
+	return n, err
+}
+
+// Expect the next JSON token to be the delimiter `delim`
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// Decode the servers of a servers.json body one by one, only the servers
+// for which `keep` returns true are kept, every server if `keep` is nil.
+// It returns the kept servers and the number of dropped ones.
+func DecodeServers(
+	r io.Reader,
+	keep func(server *twserver.Server) bool,
+) ([]*twserver.Server, int, error) {
+	var servers []*twserver.Server
+
+	dropped := 0
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, 0, err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Skipping the other fields without decoding them
+		if token != "servers" {
+			var skipped json.RawMessage
+
+			if err := dec.Decode(&skipped); err != nil {
+				return nil, 0, err
+			}
+
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, 0, err
+		}
+
+		for dec.More() {
+			var server twserver.Server
+
+			if err := dec.Decode(&server); err != nil {
+				return nil, 0, err
+			}
+
+			if keep != nil && !keep(&server) {
+				dropped++
+				continue
+			}
+
+			server.Info.AfkKnown = true
+
+			servers = append(servers, &server)
+		}
+
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, 0, err
+	}
+
+	return servers, dropped, nil
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+const serversJSON = `{
+	"communities": {"ddnet": {"name": "DDNet"}},
+	"servers": [
+		{"addresses": ["tw-0.6+udp://1.2.3.4:8303"], "info": {"game_type": "CTF"}},
+		{"addresses": ["tw-0.6+udp://1.2.3.4:8304"], "info": {"game_type": "DDraceNetwork", "clients": [{"name": "nameless tee"}]}}
+	]
+}`
+
+func TestDecodeServers(t *testing.T) {
+	servers, dropped, err := DecodeServers(strings.NewReader(serversJSON), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(servers) != 2 || dropped != 0 {
+		t.Errorf("expected 2 servers, got %d and %d dropped", len(servers), dropped)
+	}
+
+	for _, server := range servers {
+		if !server.Info.AfkKnown {
+			t.Errorf("expected the AFK state to be known for %v", server.Addresses)
+		}
+	}
+
+	servers, dropped, err = DecodeServers(
+		strings.NewReader(serversJSON),
+		func(server *twserver.Server) bool {
+			return server.Info.GameType == "CTF"
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(servers) != 1 || dropped != 1 || servers[0].Addresses[0] != "tw-0.6+udp://1.2.3.4:8303" {
+		t.Errorf("expected the CTF server only, got %v and %d dropped", servers, dropped)
+	}
+
+	if _, _, err := DecodeServers(strings.NewReader(`{"servers": {}}`), nil); err == nil {
+		t.Error("expected an error for a servers object")
+	}
+}
+
+func TestMaxBytesReader(t *testing.T) {
+	data := strings.Repeat("a", 16)
+
+	if _, err := io.ReadAll(&maxBytesReader{r: strings.NewReader(data), remaining: 16}); err != nil {
+		t.Errorf("a body of exactly the limit must be read, got %v", err)
+	}
+
+	_, err := io.ReadAll(&maxBytesReader{r: strings.NewReader(data), remaining: 15})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge, got %v", err)
+	}
+
+	_, _, err = DecodeServers(&maxBytesReader{r: strings.NewReader(serversJSON), remaining: 64}, nil)
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge while decoding, got %v", err)
+	}
+}
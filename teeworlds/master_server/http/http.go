@@ -1,41 +1,159 @@
 package http
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 )
 
 var (
+	// Default HTTP request timeout
+	DefaultTimeout = 10 * time.Second
+
 	// Default HTTP client
-	httpDefaultClient = http.Client{Timeout: 10 * time.Second}
+	httpDefaultClient = http.Client{Timeout: DefaultTimeout}
+
+	// Maximum number of bytes read after the decoded body
+	drainBytes int64 = 64 << 10
 )
 
+// Validators of a previous response, sent to only get the body if it changed
+type CacheValidators struct {
+	// ETag header of the previous response
+	ETag string
+	// Last-Modified header of the previous response
+	LastModified string
+}
+
+// HTTP response informations
+type Response struct {
+	// Whether the server answered that the body did not change
+	NotModified bool
+	// Validators of the response, to send with the next request
+	Validators CacheValidators
+	// Number of body bytes received, before decompression
+	Bytes uint64
+}
+
+// Reader counting the bytes read
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += uint64(n)
+
+	return n, err
+}
+
 // Perform a HTTP get request, then fill the
 // `target` struct with the body JSON reponse
 func HTTPGetJson(ctx context.Context, c *http.Client, url string, target any) error {
+	_, err := HTTPGet(
+		ctx,
+		c,
+		url,
+		CacheValidators{},
+		DefaultMaxResponseBytes,
+		func(r io.Reader) error {
+			return json.NewDecoder(r).Decode(target)
+		},
+	)
+
+	return err
+}
+
+// Perform a conditional HTTP get request with the `validators` of
+// a previous response, then give the decompressed body to `decode`,
+// at most `maxBytes` bytes of it. `decode` is not called if the body
+// did not change.
+func HTTPGet(
+	ctx context.Context,
+	c *http.Client,
+	url string,
+	validators CacheValidators,
+	maxBytes int64,
+	decode func(r io.Reader) error,
+) (Response, error) {
+	var response Response
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("creating request: GET %q: %v", url, err)
+		return response, fmt.Errorf("creating request: GET %q: %v", url, err)
+	}
+
+	// Set explicitly, the body is not decompressed by the transport anymore
+	// and its compressed size can be counted. Brotli is deliberately not
+	// offered, the standard library has no decoder for it.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
 	}
 
 	r, err := c.Do(req)
 	if err != nil {
-		return fmt.Errorf("request: %v", err)
+		return response, fmt.Errorf("request: %v", err)
 	}
 
-	defer r.Body.Close()
+	// Reading the end of the body allows the connection to be reused,
+	// whatever the response, without reading a body that never ends
+	defer func() {
+		_, _ = io.CopyN(io.Discard, r.Body, drainBytes)
+		_ = r.Body.Close()
+	}()
+
+	body := &countingReader{r: r.Body}
+
+	if r.StatusCode == http.StatusNotModified {
+		response.NotModified = true
+		response.Validators = validators
+
+		return response, nil
+	}
 
 	if r.StatusCode != http.StatusOK {
-		return fmt.Errorf("response status: %s", r.Status)
+		return response, fmt.Errorf("response status: %s", r.Status)
 	}
 
-	err = json.NewDecoder(r.Body).Decode(target)
+	var reader io.Reader = body
+
+	switch r.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return response, fmt.Errorf("gzip response: %v", err)
+		}
+
+		defer gz.Close()
+
+		reader = gz
+	default:
+		return response, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	// Limiting the decompressed body, a small compressed body may be huge
+	err = decode(&maxBytesReader{r: reader, remaining: maxBytes})
 	if err != nil {
-		return err
+		return response, err
+	}
+
+	response.Bytes = body.n
+	response.Validators = CacheValidators{
+		ETag:         r.Header.Get("ETag"),
+		LastModified: r.Header.Get("Last-Modified"),
 	}
 
-	return nil
+	return response, nil
 }
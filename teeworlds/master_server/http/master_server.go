@@ -2,9 +2,13 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
-	"strings"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,6 +16,16 @@ import (
 	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
 )
 
+// Order in which the urls of a master server are tried
+type Strategy string
+
+const (
+	// Try the urls in order, starting with the first one on every refresh
+	StrategyFailover Strategy = "failover"
+	// Start with the url following the last tried one on every refresh
+	StrategyRoundRobin Strategy = "round_robin"
+)
+
 var (
 	// Default HTTP master server urls
 	MasterServerHTTPUrls = []string{
@@ -21,30 +35,77 @@ var (
 
 	// Master server protocol
 	MasterServerProtocol = "http"
+
+	// Supported url strategies
+	Strategies = []Strategy{StrategyFailover, StrategyRoundRobin}
 )
 
 // HTTP master server controller
 type MasterServerHTTP struct {
-	// Master server url
-	url string
+	// Master server urls, the first one identifies the master server
+	urls []string
+	// Order in which the urls are tried
+	strategy Strategy
+	// Index of the url tried first by the next round robin refresh
+	next int
+	// Url that served `servers`, empty until a refresh succeeds
+	source string
+	// Validators of the `source` response
+	validators CacheValidators
 	// Represents the Teeworlds servers
 	servers []*twserver.Server
+	// Servers kept while decoding a response, nil keeps every server
+	filters []*twserver.Filter
+	// Number of servers of the last response dropped by `filters`
+	filtered int
+	// Number of players of the last response, `filters` included
+	totalPlayers int
+	// Maximum size of a decompressed response body
+	maxResponseBytes int64
 	// HTTP client used to perform every requests
 	httpClient *http.Client
 	// HTTP metrics
 	metrics masterserver.MasterServerMetrics
-	// Mutex protecting `servers` and `metrics`
+	// Health of each url, in the `urls` order
+	mirrors []masterserver.MirrorHealth
+	// Mutex protecting `next`, `source`, `validators`, `servers`,
+	// `filters`, `filtered`, `totalPlayers`, `metrics` and `mirrors`
 	mu sync.Mutex
 }
 
 // Creates a new MasterServerHTTP struct
 func NewMasterServer(url string) *MasterServerHTTP {
-	return &MasterServerHTTP{
-		url:        url,
-		servers:    []*twserver.Server{},
-		httpClient: &httpDefaultClient,
-		metrics:    masterserver.MasterServerMetrics{},
+	ms, _ := NewMirroredMasterServer([]string{url}, StrategyFailover)
+
+	return ms
+}
+
+// Creates a new MasterServerHTTP struct serving the same servers
+// from several urls, they are tried depending on `strategy`
+func NewMirroredMasterServer(urls []string, strategy Strategy) (*MasterServerHTTP, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("missing master server url")
+	}
+
+	if !slices.Contains(Strategies, strategy) {
+		return nil, fmt.Errorf("invalid url strategy %q", strategy)
+	}
+
+	mirrors := make([]masterserver.MirrorHealth, len(urls))
+
+	for i, url := range urls {
+		mirrors[i].URL = url
 	}
+
+	return &MasterServerHTTP{
+		urls:             slices.Clone(urls),
+		strategy:         strategy,
+		servers:          []*twserver.Server{},
+		maxResponseBytes: DefaultMaxResponseBytes,
+		httpClient:       &httpDefaultClient,
+		metrics:          masterserver.MasterServerMetrics{},
+		mirrors:          mirrors,
+	}, nil
 }
 
 // Create a new default MasterServerHTTP struct
@@ -52,9 +113,14 @@ func NewDefaultMasterServer() *MasterServerHTTP {
 	return NewMasterServer(MasterServerHTTPUrls[0])
 }
 
-// Get the master server HTTP(s) url
+// Get the master server HTTP(s) url, the first one if it has several
 func (ms *MasterServerHTTP) Url() string {
-	return ms.url
+	return ms.urls[0]
+}
+
+// Get every master server HTTP(s) url
+func (ms *MasterServerHTTP) Urls() []string {
+	return slices.Clone(ms.urls)
 }
 
 // Get the master server metadata
@@ -70,6 +136,34 @@ func (ms *MasterServerHTTP) SetHTTPClient(httpClient *http.Client) {
 	ms.httpClient = httpClient
 }
 
+// Set the maximum size of a decompressed response body
+func (ms *MasterServerHTTP) SetMaxResponseBytes(maxBytes int64) {
+	ms.maxResponseBytes = maxBytes
+}
+
+// Set the filters applied while decoding the servers, the next
+// refresh downloads every server again with the new filters
+func (ms *MasterServerHTTP) SetFilters(filters ...*twserver.Filter) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.filters = filters
+	ms.validators = CacheValidators{}
+}
+
+// Get a function checking if a server passes every filter
+func matchFilters(filters []*twserver.Filter) func(server *twserver.Server) bool {
+	return func(server *twserver.Server) bool {
+		for _, filter := range filters {
+			if !filter.Match(server) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
 // Get Teeworlds servers
 func (ms *MasterServerHTTP) Servers() ([]*twserver.Server, error) {
 	ms.mu.Lock()
@@ -78,41 +172,165 @@ func (ms *MasterServerHTTP) Servers() ([]*twserver.Server, error) {
 	return ms.servers, nil
 }
 
+// Get the validators to send to an url, only the url that served
+// the current servers can answer that they did not change
+func (ms *MasterServerHTTP) urlValidators(url string) CacheValidators {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if url != ms.source {
+		return CacheValidators{}
+	}
+
+	return ms.validators
+}
+
+// Get the indexes of the urls in the order they have to be tried
+func (ms *MasterServerHTTP) urlsOrder() []int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	start := 0
+
+	if ms.strategy == StrategyRoundRobin {
+		start = ms.next
+		ms.next = (ms.next + 1) % len(ms.urls)
+	}
+
+	order := make([]int, len(ms.urls))
+
+	for i := range order {
+		order[i] = (start + i) % len(ms.urls)
+	}
+
+	return order
+}
+
+// Update the health of an url after a request
+func (ms *MasterServerHTTP) updateMirror(i int, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	mirror := &ms.mirrors[i]
+	mirror.Up = err == nil
+
+	if err != nil {
+		mirror.FailedRequestCount++
+		return
+	}
+
+	mirror.SuccessRequestCount++
+	mirror.LastSuccess = time.Now()
+}
+
 // Refresh the Teeworlds servers with a context stored within the struct,
 // this method has to be called at least one time
 // if you want to get data.
+// Every url is tried until one of them succeeds, the servers are kept
+// if the url that served them answers that they did not change.
 func (ms *MasterServerHTTP) RefreshWithContext(ctx context.Context) error {
-	var serversField twserver.Servers
 	var servers []*twserver.Server
+	var filtered int
+	var totalPlayers int
+	var response Response
+	var errs []error
 
-	start := time.Now()
+	ms.mu.Lock()
+	filters := ms.filters
+	ms.mu.Unlock()
 
-	err := HTTPGetJson(ctx, ms.httpClient, ms.url, &serversField)
+	match := matchFilters(filters)
 
-	elapsed := time.Since(start).Seconds()
+	// Counting the players of every server, the filtered ones included
+	keep := func(server *twserver.Server) bool {
+		totalPlayers += len(server.Info.Clients)
 
-	// Failed HTTP request
-	if err != nil {
+		return match(server)
+	}
+
+	source := ""
+	elapsed := 0.0
+
+	for _, i := range ms.urlsOrder() {
+		var err error
+
+		url := ms.urls[i]
+
+		start := time.Now()
+
+		response, err = HTTPGet(
+			ctx,
+			ms.httpClient,
+			url,
+			ms.urlValidators(url),
+			ms.maxResponseBytes,
+			func(r io.Reader) error {
+				var err error
+
+				// Decoding the servers one by one, without the filtered ones
+				totalPlayers = 0
+				servers, filtered, err = DecodeServers(r, keep)
+
+				return err
+			},
+		)
+
+		elapsed = time.Since(start).Seconds()
+
+		ms.updateMirror(i, err)
+
+		if err == nil {
+			source = url
+			break
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %v", url, err))
+
+		// The next urls would fail the same way
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// Failed HTTP request on every url
+	if source == "" {
 		ms.mu.Lock()
 		ms.metrics.FailedRefreshCount++
+		ms.metrics.Up = false
 		ms.mu.Unlock()
 
-		return err
+		return errors.Join(errs...)
 	}
 
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	ms.metrics.RequestTime = uint(elapsed)
+	ms.metrics.RequestTime = elapsed
 
 	// Success HTTP request
 	ms.metrics.SuccessRefreshCount++
+	ms.metrics.LastSuccess = time.Now()
+	ms.metrics.ResponseBytes = response.Bytes
+	ms.metrics.Up = true
+
+	// The servers of `source` did not change
+	if response.NotModified {
+		ms.metrics.NotModifiedCount++
 
-	for _, server := range serversField.Servers {
-		servers = append(servers, &server)
+		return nil
 	}
 
 	ms.servers = servers
+	ms.filtered = filtered
+	ms.totalPlayers = totalPlayers
+	ms.source = source
+
+	// Filters set during the refresh need every server again
+	if slices.Equal(filters, ms.filters) {
+		ms.validators = response.Validators
+	} else {
+		ms.validators = CacheValidators{}
+	}
 
 	return nil
 }
@@ -127,22 +345,37 @@ func (ms *MasterServerHTTP) Refresh() error {
 	return ms.RefreshWithoutContext()
 }
 
-// Get server informations
+// Get server informations, the host and the port must match exactly
+// one of the server addresses, whatever its scheme
 func (ms *MasterServerHTTP) Server(host string, port uint16) (*twserver.Server, error) {
+	target, err := twserver.ParseAddress(net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)))
+	if err != nil {
+		return nil, err
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	targetAddress := fmt.Sprintf("%s:%d", host, port)
-
 	for _, server := range ms.servers {
-		for _, address := range server.Addresses {
-			if strings.HasSuffix(address, targetAddress) {
+		for _, address := range server.ParsedAddresses() {
+			if address.SameHostPort(target) {
 				return server, nil
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("the server %s is not registered", targetAddress)
+	return nil, fmt.Errorf("the server %s is not registered", target.HostPort())
+}
+
+// Get the master server metrics, `ms.mu` must be held
+func (ms *MasterServerHTTP) metricsLocked() masterserver.MasterServerMetrics {
+	metrics := ms.metrics
+
+	if len(ms.mirrors) > 1 {
+		metrics.Mirrors = slices.Clone(ms.mirrors)
+	}
+
+	return metrics
 }
 
 // Get the master server metrics
@@ -150,5 +383,28 @@ func (ms *MasterServerHTTP) Metrics() masterserver.MasterServerMetrics {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	return ms.metrics
+	return ms.metricsLocked()
+}
+
+// Get the servers and the metrics at once, with the url
+// that served the servers if there are several ones
+func (ms *MasterServerHTTP) Snapshot() masterserver.MasterServerSnapshot {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var source string
+
+	if len(ms.urls) > 1 {
+		source = ms.source
+	}
+
+	return masterserver.MasterServerSnapshot{
+		Metadata:     ms.Metadata(),
+		Source:       source,
+		Servers:      ms.servers,
+		Filtered:     ms.filtered,
+		TotalServers: len(ms.servers) + ms.filtered,
+		TotalPlayers: ms.totalPlayers,
+		Metrics:      ms.metricsLocked(),
+	}
 }
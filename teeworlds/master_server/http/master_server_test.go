@@ -1,7 +1,12 @@
 package http
 
 import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
 )
 
 func TestMasterServerRefresh(t *testing.T) {
@@ -41,3 +46,167 @@ func TestMasterServerServers(t *testing.T) {
 		t.Errorf("no servers found")
 	}
 }
+
+func TestMasterServerServerExactMatch(t *testing.T) {
+	ms := NewDefaultMasterServer()
+
+	ms.servers = []*twserver.Server{
+		{Addresses: []string{"tw-0.6+udp://11.2.3.4:8303"}},
+		{Addresses: []string{"tw-0.6+udp://[2001:db8::1]:8303", "tw-0.7+udp://1.2.3.4:8304"}},
+	}
+
+	if _, err := ms.Server("1.2.3.4", 8303); err == nil {
+		t.Error("1.2.3.4:8303 must not match 11.2.3.4:8303")
+	}
+
+	if server, err := ms.Server("1.2.3.4", 8304); err != nil || server != ms.servers[1] {
+		t.Errorf("expected the second server, got %v, %v", server, err)
+	}
+
+	if server, err := ms.Server("2001:db8:0::1", 8303); err != nil || server != ms.servers[1] {
+		t.Errorf("expected the second server, got %v, %v", server, err)
+	}
+}
+
+func TestMasterServerMirrorsFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"servers": [{"addresses": ["tw-0.6+udp://1.2.3.4:8303"]}]}`))
+	}))
+	defer up.Close()
+
+	ms, err := NewMirroredMasterServer([]string{down.URL, up.URL}, StrategyFailover)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.RefreshWithoutContext(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := ms.Snapshot()
+
+	if snapshot.Source != up.URL || len(snapshot.Servers) != 1 {
+		t.Errorf("expected the servers of %s, got %+v", up.URL, snapshot)
+	}
+
+	if ms.Metadata().Address != down.URL || snapshot.Metadata.Address != down.URL {
+		t.Errorf("the first url must identify the master server")
+	}
+
+	mirrors := snapshot.Metrics.Mirrors
+	if len(mirrors) != 2 || mirrors[0].Up || mirrors[0].FailedRequestCount != 1 || !mirrors[1].Up {
+		t.Errorf("unexpected mirrors health %+v", mirrors)
+	}
+
+	up.Close()
+
+	if err := ms.RefreshWithoutContext(); err == nil {
+		t.Error("expected an error when every url fails")
+	}
+}
+
+func TestMasterServerMirrorsRoundRobin(t *testing.T) {
+	urls := []string{"http://a", "http://b", "http://c"}
+
+	ms, err := NewMirroredMasterServer(urls, StrategyRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if first := ms.urlsOrder()[0]; first != i%len(urls) {
+			t.Errorf("expected the url %d first, got %d", i%len(urls), first)
+		}
+	}
+
+	if _, err := NewMirroredMasterServer(urls, "random"); err == nil {
+		t.Error("expected an invalid strategy error")
+	}
+}
+
+func TestMasterServerConditionalRefresh(t *testing.T) {
+	requests := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("gzip has not been negotiated")
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gz := gzip.NewWriter(w)
+		_, _ = gz.Write([]byte(`{"servers": [{"addresses": ["tw-0.6+udp://1.2.3.4:8303"]}]}`))
+		_ = gz.Close()
+	}))
+	defer ts.Close()
+
+	ms := NewMasterServer(ts.URL)
+
+	if err := ms.RefreshWithoutContext(); err != nil {
+		t.Fatal(err)
+	}
+
+	if metrics := ms.Metrics(); metrics.ResponseBytes == 0 || metrics.NotModifiedCount != 0 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+
+	if err := ms.RefreshWithoutContext(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := ms.Snapshot()
+
+	if requests != 2 || snapshot.Metrics.NotModifiedCount != 1 || snapshot.Metrics.SuccessRefreshCount != 2 {
+		t.Errorf("expected a not modified refresh, got %+v", snapshot.Metrics)
+	}
+
+	if len(snapshot.Servers) != 1 {
+		t.Errorf("the servers must be kept, got %v", snapshot.Servers)
+	}
+}
+
+func TestMasterServerFiltersWhileDecoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(serversJSON))
+	}))
+	defer ts.Close()
+
+	ms := NewMasterServer(ts.URL)
+	ms.SetFilters(nil, &twserver.Filter{
+		Exclude: &twserver.FilterRule{GameTypes: []string{"ddracenetwork"}},
+	})
+
+	if err := ms.RefreshWithoutContext(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := ms.Snapshot()
+
+	if len(snapshot.Servers) != 1 || snapshot.Filtered != 1 {
+		t.Errorf("expected 1 server and 1 filtered, got %d and %d", len(snapshot.Servers), snapshot.Filtered)
+	}
+
+	// The totals include the filtered servers
+	if snapshot.TotalServers != 2 || snapshot.TotalPlayers != 1 {
+		t.Errorf("expected 2 servers and 1 player in total, got %d and %d", snapshot.TotalServers, snapshot.TotalPlayers)
+	}
+
+	ms.SetMaxResponseBytes(64)
+
+	if err := ms.RefreshWithoutContext(); err == nil {
+		t.Error("expected a too large response error")
+	}
+}
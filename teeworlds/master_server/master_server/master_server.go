@@ -1,6 +1,9 @@
 package masterserver
 
 import (
+	"context"
+	"time"
+
 	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
 )
 
@@ -12,19 +15,103 @@ type MasterServerMetadata struct {
 	Address string
 }
 
+// Health of a master server mirror url
+type MirrorHealth struct {
+	// Mirror url
+	URL string
+	// Mirror success request count
+	SuccessRequestCount uint
+	// Mirror failed request count
+	FailedRequestCount uint
+	// Time of the last successful request, zero if none succeeded
+	LastSuccess time.Time
+	// Indicating if the last request succeeded
+	Up bool
+}
+
 // Master server metrics
 type MasterServerMetrics struct {
 	// Master server success refresh count
 	SuccessRefreshCount uint
 	// Master server failed refresh count
 	FailedRefreshCount uint
-	// Master server request time in seconds
-	RequestTime uint
+	// Request time in seconds of the last successful refresh
+	RequestTime float64
+	// Duration in seconds of each phase of the last successful refresh,
+	// if the master server times them
+	PhasesTime map[string]float64
+	// Time of the last successful refresh, zero if none succeeded
+	LastSuccess time.Time
+	// Number of body bytes received by the last successful refresh
+	ResponseBytes uint64
+	// Number of successful refreshes that did not change the servers
+	NotModifiedCount uint
+	// Health of each mirror url, if the master server has several ones
+	Mirrors []MirrorHealth
+	// Indicating if the last refresh succeeded
+	Up bool
+}
+
+// Immutable view of a master server data, taken at once so every metric
+// of a scrape derives from the same refresh
+type MasterServerSnapshot struct {
+	// Master server metadata
+	Metadata MasterServerMetadata
+	// Url that served the servers, for a master server with several ones
+	Source string
+	// Teeworlds servers of the last successful refresh, must not be modified
+	Servers []*server.Server
+	// Number of servers removed by the filters
+	Filtered int
+	// Number of servers and of players before the filters
+	TotalServers int
+	TotalPlayers int
+	// Whether the servers are not exported because the last
+	// successful refresh is too old
+	Stale bool
+	// Master server metrics
+	Metrics MasterServerMetrics
+}
+
+// Count the clients of servers
+func CountPlayers(servers []*server.Server) int {
+	n := 0
+
+	for _, s := range servers {
+		if s != nil {
+			n += len(s.Info.Clients)
+		}
+	}
+
+	return n
 }
 
 type MasterServer interface {
 	Servers() ([]*server.Server, error)
 	Refresh() error
+	RefreshWithContext(ctx context.Context) error
 	Metadata() MasterServerMetadata
 	Metrics() MasterServerMetrics
+	Snapshot() MasterServerSnapshot
+}
+
+// Master server applying the filters while refreshing, rather than
+// keeping the servers it does not export
+type Filterer interface {
+	SetFilters(filters ...*server.Filter)
+}
+
+// Master server serving the same servers from several urls
+type Mirrorer interface {
+	Urls() []string
+}
+
+// Master server holding a connection that must be opened before refreshing
+type Connecter interface {
+	Connect() error
+}
+
+// Master server holding a connection that must be closed on shutdown
+type Disconnecter interface {
+	Disconnect() error
 }
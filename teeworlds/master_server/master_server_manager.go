@@ -1,21 +1,31 @@
 package masterserver
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
 	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
 )
 
 // Master server entry used to register a master server
 type MasterServerManagerEntry struct {
 	// Master server implementation
 	MasterServer masterserver.MasterServer
-	// Cooldown between each refresh
+	// Cooldown in seconds between each refresh,
+	// `DefaultRefreshCooldown` if zero
 	RefreshCooldown uint
-	// Refresh state
-	IsRefreshing bool
+	// Servers exported from this master server, nil exports every server
+	Filter *server.Filter
+	// Age of the last successful refresh after which the servers
+	// are not exported anymore, zero keeps them forever
+	StaleAfter time.Duration
+	// Refresh goroutine, nil until the entry starts refreshing
+	worker *refreshWorker
 }
 
 // Create a new master server entry
@@ -26,16 +36,24 @@ func NewMasterServerManagerEntry(
 	return &MasterServerManagerEntry{
 		MasterServer:    masterServer,
 		RefreshCooldown: refreshcooldown,
-		IsRefreshing:    false,
 	}
 }
 
 // Map used to manage master servers
-type MasterServersMap map[masterserver.MasterServerMetadata]MasterServerManagerEntry
+type MasterServersMap map[masterserver.MasterServerMetadata]*MasterServerManagerEntry
 
 // Master server manager
 type MasterServerManager struct {
 	masterServers MasterServersMap
+	// Servers exported from every master server, nil exports every server
+	filter *server.Filter
+	// Receives the refresh durations, may be nil
+	observer RefreshObserver
+	// Receives the removed master servers, may be nil
+	removalObserver RemovalObserver
+	// Mutex protecting `masterServers`, `filter`, `observer`,
+	// `removalObserver` and the entries workers
+	mu sync.Mutex
 }
 
 // Create a new master server manager
@@ -45,7 +63,52 @@ func NewMasterServerManager() *MasterServerManager {
 	}
 }
 
-// Register a master server
+// Give the filters of an entry to its master server, if it applies them
+// itself, `msm.mu` must be held
+func (msm *MasterServerManager) setFilters(entry *MasterServerManagerEntry) {
+	filterer, ok := entry.MasterServer.(masterserver.Filterer)
+	if ok {
+		filterer.SetFilters(msm.filter, entry.Filter)
+	}
+}
+
+// Close the connection of a master server, if it has one
+func disconnect(masterServer masterserver.MasterServer) {
+	disconnecter, ok := masterServer.(masterserver.Disconnecter)
+	if !ok {
+		return
+	}
+
+	if err := disconnecter.Disconnect(); err != nil {
+		debug.Debug(err.Error())
+	}
+}
+
+// Stop refreshing a master server entry, close its connection
+// to interrupt a pending refresh, then wait for the worker to return
+func stopEntry(entry *MasterServerManagerEntry) {
+	if entry.worker != nil {
+		entry.worker.stop()
+	}
+
+	disconnect(entry.MasterServer)
+
+	if entry.worker == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), WorkerStopTimeout)
+	defer cancel()
+
+	if !entry.worker.wait(ctx) {
+		debug.Debug(
+			"the refresh of %s is still running after being stopped",
+			entry.MasterServer.Metadata().Address,
+		)
+	}
+}
+
+// Register a master server, it replaces the one with the same metadata
 func (msm *MasterServerManager) Register(entry MasterServerManagerEntry) error {
 	masterServer := entry.MasterServer
 
@@ -53,20 +116,122 @@ func (msm *MasterServerManager) Register(entry MasterServerManagerEntry) error {
 		return fmt.Errorf("masterServer is nil")
 	}
 
-	msm.masterServers[masterServer.Metadata()] = entry
+	metadata := masterServer.Metadata()
+
+	entry.worker = nil
+
+	msm.mu.Lock()
+	previous, found := msm.masterServers[metadata]
+	msm.masterServers[metadata] = &entry
+	msm.setFilters(&entry)
+	msm.mu.Unlock()
+
+	// The previous entry is stopped without holding the lock,
+	// so the collection is not blocked by a pending refresh
+	if found {
+		stopEntry(previous)
+	}
+
+	return nil
+}
+
+// Remove the master servers `removed`, register `added` and set the
+// filter applied to every master server in one step, so the collection
+// never sees a partially applied configuration. The replaced entries
+// are stopped afterwards, without holding the lock, then the removal
+// observer receives the master servers that are not added back.
+func (msm *MasterServerManager) Replace(
+	removed []masterserver.MasterServerMetadata,
+	added []*MasterServerManagerEntry,
+	filter *server.Filter,
+) error {
+	for _, entry := range added {
+		if entry == nil || entry.MasterServer == nil {
+			return fmt.Errorf("masterServer is nil")
+		}
+	}
+
+	var (
+		stopped []*MasterServerManagerEntry
+		// Removed master servers that are not added back
+		forgotten []masterserver.MasterServerMetadata
+	)
+
+	msm.mu.Lock()
+
+	for _, metadata := range removed {
+		if entry, found := msm.masterServers[metadata]; found {
+			stopped = append(stopped, entry)
+			forgotten = append(forgotten, metadata)
+			delete(msm.masterServers, metadata)
+		}
+	}
+
+	for _, entry := range added {
+		metadata := entry.MasterServer.Metadata()
+
+		if previous, found := msm.masterServers[metadata]; found {
+			stopped = append(stopped, previous)
+		}
+
+		entry.worker = nil
+		msm.masterServers[metadata] = entry
+	}
+
+	forgotten = slices.DeleteFunc(forgotten, func(metadata masterserver.MasterServerMetadata) bool {
+		_, found := msm.masterServers[metadata]
+		return found
+	})
+
+	msm.filter = filter
+
+	for _, entry := range msm.masterServers {
+		msm.setFilters(entry)
+	}
+
+	removalObserver := msm.removalObserver
+
+	msm.mu.Unlock()
+
+	for _, entry := range stopped {
+		stopEntry(entry)
+	}
+
+	if removalObserver != nil {
+		for _, metadata := range forgotten {
+			removalObserver(metadata)
+		}
+	}
 
 	return nil
 }
 
-// Delete a master server
+// Delete a master server, it stops its worker and waits for it
 func (msm *MasterServerManager) Delete(masterServerMetadata masterserver.MasterServerMetadata) {
+	msm.mu.Lock()
+	entry, found := msm.masterServers[masterServerMetadata]
 	delete(msm.masterServers, masterServerMetadata)
+	removalObserver := msm.removalObserver
+	msm.mu.Unlock()
+
+	if !found {
+		return
+	}
+
+	stopEntry(entry)
+
+	if removalObserver != nil {
+		removalObserver(masterServerMetadata)
+	}
 }
 
 // Return a Slice of pointers on master server
 func (msm *MasterServerManager) MasterServers() []*masterserver.MasterServer {
 	var masterServers []*masterserver.MasterServer
 
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+
 	for _, entry := range msm.masterServers {
 		masterServers = append(masterServers, &entry.MasterServer)
 	}
@@ -74,55 +239,157 @@ func (msm *MasterServerManager) MasterServers() []*masterserver.MasterServer {
 	return masterServers
 }
 
-// Goroutine that start refreshing a master server
-func startRefresh(entry *MasterServerManagerEntry, errorCh chan error) {
-	if entry == nil {
-		errorCh <- fmt.Errorf("missing entry")
-		return
+// Set the observer receiving the refresh durations of the workers
+// started afterwards
+func (msm *MasterServerManager) SetRefreshObserver(observer RefreshObserver) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+
+	msm.observer = observer
+}
+
+// Set the observer receiving the master servers removed by `Replace`
+// or `Delete`
+func (msm *MasterServerManager) SetRemovalObserver(observer RemovalObserver) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+
+	msm.removalObserver = observer
+}
+
+// Set the filter applied to the servers of every master server
+func (msm *MasterServerManager) SetFilter(filter *server.Filter) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
+
+	msm.filter = filter
+
+	for _, entry := range msm.masterServers {
+		msm.setFilters(entry)
 	}
+}
 
-	masterServer := (*entry).MasterServer
+// Remove the servers not passing the filters from a snapshot
+func filterSnapshot(
+	snapshot masterserver.MasterServerSnapshot,
+	filters ...*server.Filter,
+) masterserver.MasterServerSnapshot {
+	var servers []*server.Server
 
-	if masterServer == nil {
-		errorCh <- fmt.Errorf("missing masterServer")
-		return
+	for _, s := range snapshot.Servers {
+		if !slices.ContainsFunc(filters, func(filter *server.Filter) bool {
+			return !filter.Match(s)
+		}) {
+			servers = append(servers, s)
+		}
 	}
 
-	errorCh <- nil
+	// Adding to the servers the master server already filtered
+	snapshot.Filtered += len(snapshot.Servers) - len(servers)
+	snapshot.Servers = servers
 
-	metadata := masterServer.Metadata()
-	duration := time.Duration((*entry).RefreshCooldown) * time.Second
-
-	(*entry).IsRefreshing = true
-
-	for {
-		err := masterServer.Refresh()
-		if err != nil {
-			debug.Debug(
-				"could not refresh %s with protocol %s",
-				metadata.Address,
-				metadata.Protocol,
-			)
+	return snapshot
+}
+
+// Remove the servers of a snapshot if its last successful refresh
+// is older than `staleAfter`
+func expireSnapshot(
+	snapshot masterserver.MasterServerSnapshot,
+	staleAfter time.Duration,
+	now time.Time,
+) masterserver.MasterServerSnapshot {
+	lastSuccess := snapshot.Metrics.LastSuccess
+
+	if staleAfter <= 0 || lastSuccess.IsZero() || now.Sub(lastSuccess) <= staleAfter {
+		return snapshot
+	}
+
+	snapshot.Servers = nil
+	snapshot.Filtered = 0
+	snapshot.TotalServers = 0
+	snapshot.TotalPlayers = 0
+	snapshot.Stale = true
+
+	return snapshot
+}
+
+// Return a snapshot of every master server, without the stale
+// and the filtered servers
+func (msm *MasterServerManager) Snapshots() []masterserver.MasterServerSnapshot {
+	var entries []*MasterServerManagerEntry
+
+	msm.mu.Lock()
+	filter := msm.filter
+
+	for _, entry := range msm.masterServers {
+		entries = append(entries, entry)
+	}
+	msm.mu.Unlock()
+
+	snapshots := make([]masterserver.MasterServerSnapshot, 0, len(entries))
+	now := time.Now()
+
+	for _, entry := range entries {
+		snapshot := expireSnapshot(entry.MasterServer.Snapshot(), entry.StaleAfter, now)
+
+		// A `masterserver.Filterer` has already filtered its servers
+		_, filtered := entry.MasterServer.(masterserver.Filterer)
+
+		if !filtered && (filter != nil || entry.Filter != nil) {
+			snapshot = filterSnapshot(snapshot, filter, entry.Filter)
 		}
 
-		time.Sleep(duration)
+		snapshots = append(snapshots, snapshot)
 	}
+
+	return snapshots
 }
 
-// Control loop that start refreshing every master server
-func (msm *MasterServerManager) StartRefresh() {
-	errorCh := make(chan error)
+// Start a refresh worker for every master server that has none yet,
+// the workers stop when `ctx` is done
+func (msm *MasterServerManager) StartRefresh(ctx context.Context) {
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
 
 	for _, entry := range msm.masterServers {
-		if entry.IsRefreshing {
+		if entry.worker != nil {
 			continue
 		}
 
-		go startRefresh(&entry, errorCh)
+		entry.worker = startRefreshWorker(
+			ctx,
+			entry.MasterServer,
+			entry.RefreshCooldown,
+			msm.observer,
+		)
+	}
+}
+
+// Stop every refresh worker and wait for them, then close the master server
+// connections. If `ctx` is done before, the connections are closed anyway
+// to interrupt the pending refreshes.
+func (msm *MasterServerManager) Shutdown(ctx context.Context) error {
+	var err error
+
+	msm.mu.Lock()
+	defer msm.mu.Unlock()
 
-		err := <-errorCh
-		if err != nil {
-			debug.Debug(err.Error())
+	for _, entry := range msm.masterServers {
+		if entry.worker != nil {
+			entry.worker.stop()
 		}
 	}
+
+	for _, entry := range msm.masterServers {
+		if entry.worker != nil && !entry.worker.wait(ctx) {
+			err = ctx.Err()
+			break
+		}
+	}
+
+	for _, entry := range msm.masterServers {
+		disconnect(entry.MasterServer)
+	}
+
+	return err
 }
@@ -0,0 +1,296 @@
+package masterserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+// Master server counting its refreshes
+type fakeMasterServer struct {
+	address   string
+	refreshes atomic.Int64
+	// Phases durations reported by the metrics
+	phases map[string]float64
+}
+
+func (ms *fakeMasterServer) Servers() ([]*server.Server, error) {
+	return nil, nil
+}
+
+func (ms *fakeMasterServer) Refresh() error {
+	return ms.RefreshWithContext(context.Background())
+}
+
+func (ms *fakeMasterServer) RefreshWithContext(ctx context.Context) error {
+	ms.refreshes.Add(1)
+
+	return nil
+}
+
+func (ms *fakeMasterServer) Metadata() masterserver.MasterServerMetadata {
+	return masterserver.MasterServerMetadata{
+		Protocol: "fake",
+		Address:  ms.address,
+	}
+}
+
+func (ms *fakeMasterServer) Metrics() masterserver.MasterServerMetrics {
+	return masterserver.MasterServerMetrics{PhasesTime: ms.phases}
+}
+
+func (ms *fakeMasterServer) Snapshot() masterserver.MasterServerSnapshot {
+	return masterserver.MasterServerSnapshot{Metadata: ms.Metadata()}
+}
+
+func TestStartRefreshOnce(t *testing.T) {
+	msm := NewMasterServerManager()
+	ms := &fakeMasterServer{address: "localhost:8283"}
+
+	if err := msm.Register(*NewMasterServerManagerEntry(ms, 3600)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every call must not start another worker
+	for i := 0; i < 5; i++ {
+		msm.StartRefresh(ctx)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ms.refreshes.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if n := ms.refreshes.Load(); n != 1 {
+		t.Fatalf("expected 1 refresh, got %d", n)
+	}
+
+	snapshots := msm.Snapshots()
+	if len(snapshots) != 1 || snapshots[0].Metadata != ms.Metadata() {
+		t.Fatalf("unexpected snapshots %+v", snapshots)
+	}
+
+	msm.Delete(ms.Metadata())
+
+	if len(msm.MasterServers()) != 0 {
+		t.Fatal("expected no master server")
+	}
+}
+
+func TestRefreshObserver(t *testing.T) {
+	msm := NewMasterServerManager()
+	ms := &fakeMasterServer{
+		address: "localhost:8283",
+		phases:  map[string]float64{"infos": 0.25},
+	}
+
+	observed := make(chan string, 4)
+
+	msm.SetRefreshObserver(func(
+		metadata masterserver.MasterServerMetadata,
+		phase string,
+		seconds float64,
+	) {
+		if metadata != ms.Metadata() || seconds < 0 {
+			t.Errorf("unexpected observation %v %s %f", metadata, phase, seconds)
+		}
+
+		observed <- phase
+	})
+
+	if err := msm.Register(*NewMasterServerManagerEntry(ms, 3600)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	msm.StartRefresh(ctx)
+	defer func() { _ = msm.Shutdown(ctx) }()
+
+	for _, expected := range []string{PhaseTotal, "infos"} {
+		select {
+		case phase := <-observed:
+			if phase != expected {
+				t.Errorf("expected the phase %s, got %s", expected, phase)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the phase %s has not been observed", expected)
+		}
+	}
+}
+
+func TestMasterServerManagerConcurrency(t *testing.T) {
+	msm := NewMasterServerManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				ms := &fakeMasterServer{address: fmt.Sprintf("localhost:%d", 8000+(i+j)%4)}
+
+				_ = msm.Register(*NewMasterServerManagerEntry(ms, 1))
+				msm.StartRefresh(ctx)
+
+				for _, masterServer := range msm.MasterServers() {
+					_ = (*masterServer).Metrics()
+				}
+
+				if j%3 == 0 {
+					msm.Delete(ms.Metadata())
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+
+	if err := msm.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemovalObserver(t *testing.T) {
+	msm := NewMasterServerManager()
+	kept := &fakeMasterServer{address: "localhost:8283"}
+	removed := &fakeMasterServer{address: "localhost:8284"}
+
+	var forgotten []masterserver.MasterServerMetadata
+
+	msm.SetRemovalObserver(func(metadata masterserver.MasterServerMetadata) {
+		forgotten = append(forgotten, metadata)
+	})
+
+	for _, ms := range []*fakeMasterServer{kept, removed} {
+		if err := msm.Register(*NewMasterServerManagerEntry(ms, 3600)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A master server removed then added back is not forgotten
+	err := msm.Replace(
+		[]masterserver.MasterServerMetadata{kept.Metadata(), removed.Metadata()},
+		[]*MasterServerManagerEntry{NewMasterServerManagerEntry(kept, 60)},
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(forgotten) != 1 || forgotten[0] != removed.Metadata() {
+		t.Fatalf("expected %v to be forgotten, got %v", removed.Metadata(), forgotten)
+	}
+
+	msm.Delete(kept.Metadata())
+	msm.Delete(kept.Metadata())
+
+	if len(forgotten) != 2 || forgotten[1] != kept.Metadata() {
+		t.Fatalf("expected %v to be forgotten once, got %v", kept.Metadata(), forgotten)
+	}
+}
+
+func TestExpireSnapshot(t *testing.T) {
+	now := time.Now()
+	snapshot := masterserver.MasterServerSnapshot{
+		Servers:      []*server.Server{{}},
+		Filtered:     1,
+		TotalServers: 2,
+		Metrics:      masterserver.MasterServerMetrics{LastSuccess: now.Add(-time.Minute)},
+	}
+
+	if s := expireSnapshot(snapshot, 2*time.Minute, now); s.Stale || len(s.Servers) != 1 {
+		t.Errorf("expected a fresh snapshot, got %+v", s)
+	}
+
+	if s := expireSnapshot(snapshot, 0, now); s.Stale || len(s.Servers) != 1 {
+		t.Errorf("expected a snapshot that never expires, got %+v", s)
+	}
+
+	if s := expireSnapshot(snapshot, 30*time.Second, now); !s.Stale || len(s.Servers) != 0 || s.Filtered != 0 || s.TotalServers != 0 {
+		t.Errorf("expected a stale snapshot, got %+v", s)
+	}
+}
+
+func TestFilterSnapshotKeepsTotals(t *testing.T) {
+	snapshot := masterserver.MasterServerSnapshot{
+		Servers: []*server.Server{
+			{Info: server.ServerInfo{GameType: "CTF"}},
+			{Info: server.ServerInfo{GameType: "DM"}},
+		},
+		TotalServers: 2,
+	}
+
+	s := filterSnapshot(snapshot, &server.Filter{
+		Include: &server.FilterRule{GameTypes: []string{"ctf"}},
+	})
+
+	if len(s.Servers) != 1 || s.Filtered != 1 || s.TotalServers != 2 {
+		t.Errorf("expected 1 server, 1 filtered and 2 in total, got %+v", s)
+	}
+}
+
+// Master server filtering its servers while refreshing
+type fakeFiltererMasterServer struct {
+	fakeMasterServer
+	filters []*server.Filter
+}
+
+func (ms *fakeFiltererMasterServer) SetFilters(filters ...*server.Filter) {
+	ms.filters = filters
+}
+
+func (ms *fakeFiltererMasterServer) Snapshot() masterserver.MasterServerSnapshot {
+	return masterserver.MasterServerSnapshot{
+		Metadata: ms.Metadata(),
+		Servers:  []*server.Server{{Info: server.ServerInfo{GameType: "CTF"}}},
+		Filtered: 1,
+	}
+}
+
+func TestSnapshotsFiltererFilteredOnce(t *testing.T) {
+	msm := NewMasterServerManager()
+	ms := &fakeFiltererMasterServer{fakeMasterServer: fakeMasterServer{address: "filterer"}}
+
+	// Not matching the servers of the snapshot, it is applied by the master server
+	filter := &server.Filter{
+		Include: &server.FilterRule{GameTypes: []string{"dm"}},
+	}
+
+	msm.SetFilter(filter)
+
+	if err := msm.Register(*NewMasterServerManagerEntry(ms, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms.filters) == 0 || ms.filters[0] != filter {
+		t.Errorf("expected the filters to be set, got %v", ms.filters)
+	}
+
+	snapshots := msm.Snapshots()
+
+	if len(snapshots) != 1 || len(snapshots[0].Servers) != 1 || snapshots[0].Filtered != 1 {
+		t.Errorf("expected the servers to be filtered once, got %+v", snapshots)
+	}
+}
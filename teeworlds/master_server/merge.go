@@ -0,0 +1,66 @@
+package masterserver
+
+import (
+	"cmp"
+	"slices"
+
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+// Teeworlds server listed by one or more master servers
+type MergedServer struct {
+	// Server informations, from the first master server listing it
+	Server *server.Server
+	// Master servers listing the server
+	SeenOn []masterserver.MasterServerMetadata
+}
+
+// Merge the servers of every snapshot, the servers sharing a normalized
+// address are the same. The snapshots are walked sorted by protocol then
+// address, so the kept informations do not depend on the registration order.
+func MergeSnapshots(snapshots []masterserver.MasterServerSnapshot) []*MergedServer {
+	var merged []*MergedServer
+
+	sorted := slices.Clone(snapshots)
+	slices.SortFunc(sorted, func(a, b masterserver.MasterServerSnapshot) int {
+		return cmp.Or(
+			cmp.Compare(a.Metadata.Protocol, b.Metadata.Protocol),
+			cmp.Compare(a.Metadata.Address, b.Metadata.Address),
+		)
+	})
+
+	byAddress := make(map[string]*MergedServer)
+
+	for _, snapshot := range sorted {
+		for _, s := range snapshot.Servers {
+			if s == nil || len(s.Addresses) == 0 {
+				continue
+			}
+
+			var entry *MergedServer
+
+			for _, address := range s.Addresses {
+				if found, ok := byAddress[server.NormalizeAddress(address)]; ok {
+					entry = found
+					break
+				}
+			}
+
+			if entry == nil {
+				entry = &MergedServer{Server: s}
+				merged = append(merged, entry)
+			}
+
+			if !slices.Contains(entry.SeenOn, snapshot.Metadata) {
+				entry.SeenOn = append(entry.SeenOn, snapshot.Metadata)
+			}
+
+			for _, address := range s.Addresses {
+				byAddress[server.NormalizeAddress(address)] = entry
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,51 @@
+package masterserver
+
+import (
+	"testing"
+
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
+)
+
+func TestMergeSnapshots(t *testing.T) {
+	udp := masterserver.MasterServerMetadata{Protocol: "udp", Address: "master1.teeworlds.com:8283"}
+	http := masterserver.MasterServerMetadata{Protocol: "http", Address: "https://master1.ddnet.org/ddnet/15/servers.json"}
+
+	snapshots := []masterserver.MasterServerSnapshot{
+		{
+			Metadata: udp,
+			Servers: []*server.Server{
+				{Addresses: []string{"1.2.3.4:8303"}, Info: server.ServerInfo{Name: "udp"}},
+				{Addresses: []string{"5.6.7.8:8303"}},
+			},
+		},
+		{
+			Metadata: http,
+			Servers: []*server.Server{
+				{
+					Addresses: []string{"tw-0.6+udp://1.2.3.4:8303", "tw-0.7+udp://1.2.3.4:8304"},
+					Info:      server.ServerInfo{Name: "http"},
+				},
+			},
+		},
+	}
+
+	merged := MergeSnapshots(snapshots)
+
+	if len(merged) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(merged))
+	}
+
+	// The HTTP master server comes first once sorted
+	if merged[0].Server.Info.Name != "http" {
+		t.Errorf("expected the HTTP server informations, got %q", merged[0].Server.Info.Name)
+	}
+
+	if len(merged[0].SeenOn) != 2 {
+		t.Errorf("expected the server to be seen on 2 master servers, got %v", merged[0].SeenOn)
+	}
+
+	if len(merged[1].SeenOn) != 1 || merged[1].SeenOn[0] != udp {
+		t.Errorf("expected the server to be seen on the UDP master server, got %v", merged[1].SeenOn)
+	}
+}
@@ -0,0 +1,75 @@
+package masterserver
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"gopkg.in/yaml.v3"
+)
+
+// Creates a master server from the YAML node of its configuration entry,
+// the node also contains the common keys like `protocol` or `refresh_cooldown`
+type ProtocolFactory func(node *yaml.Node) (masterserver.MasterServer, error)
+
+var (
+	// Missing master server configuration node
+	ErrMissingProtocolConfig = fmt.Errorf("missing master server configuration")
+
+	// Registered master server protocols
+	protocols = make(map[string]ProtocolFactory)
+	// Mutex protecting `protocols`
+	protocolsMu sync.RWMutex
+)
+
+// Register a master server protocol, usable with `protocol: <name>` in the
+// configuration. It panics if the name is empty or already registered,
+// it is meant to be called from an init function.
+func RegisterProtocol(name string, factory ProtocolFactory) {
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+
+	if name == "" || factory == nil {
+		panic("master server protocol name and factory are required")
+	}
+
+	if _, found := protocols[name]; found {
+		panic(fmt.Sprintf("master server protocol %q already registered", name))
+	}
+
+	protocols[name] = factory
+}
+
+// Get the sorted names of the registered master server protocols
+func Protocols() []string {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+
+	names := make([]string, 0, len(protocols))
+
+	for name := range protocols {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Create a master server with a registered protocol
+func NewProtocolMasterServer(name string, node *yaml.Node) (masterserver.MasterServer, error) {
+	protocolsMu.RLock()
+	factory, found := protocols[name]
+	protocolsMu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("invalid master server protocol %q", name)
+	}
+
+	if node == nil {
+		return nil, ErrMissingProtocolConfig
+	}
+
+	return factory(node)
+}
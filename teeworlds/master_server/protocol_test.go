@@ -0,0 +1,57 @@
+package masterserver
+
+import (
+	"slices"
+	"testing"
+
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRegisterProtocol(t *testing.T) {
+	RegisterProtocol("fake", func(node *yaml.Node) (masterserver.MasterServer, error) {
+		var c struct {
+			Address string `yaml:"address"`
+		}
+
+		if err := node.Decode(&c); err != nil {
+			return nil, err
+		}
+
+		return &fakeMasterServer{address: c.Address}, nil
+	})
+
+	if !slices.Contains(Protocols(), "fake") {
+		t.Fatalf("the fake protocol is not registered, got %v", Protocols())
+	}
+
+	var node yaml.Node
+
+	if err := yaml.Unmarshal([]byte("protocol: fake\naddress: localhost:8283\n"), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	// The document node contains the mapping node
+	ms, err := NewProtocolMasterServer("fake", node.Content[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ms.Metadata().Address != "localhost:8283" {
+		t.Errorf("unexpected metadata %v", ms.Metadata())
+	}
+
+	if _, err := NewProtocolMasterServer("unknown", node.Content[0]); err == nil {
+		t.Error("expected an unknown protocol error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a protocol twice must panic")
+		}
+	}()
+
+	RegisterProtocol("fake", func(node *yaml.Node) (masterserver.MasterServer, error) {
+		return nil, nil
+	})
+}
@@ -0,0 +1,138 @@
+package masterserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
+	masterserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+)
+
+// Phase of the observed duration covering the whole refresh
+const PhaseTotal = "total"
+
+// Receives the duration in seconds of every refresh, for the whole refresh
+// with `PhaseTotal` then for each phase timed by the master server
+type RefreshObserver func(
+	metadata masterserver.MasterServerMetadata,
+	phase string,
+	seconds float64,
+)
+
+// Receives the metadata of a master server once it is removed from the
+// manager and its worker is stopped, rather than replaced by another one
+type RemovalObserver func(metadata masterserver.MasterServerMetadata)
+
+var (
+	// Refresh cooldown in seconds used when an entry has none
+	DefaultRefreshCooldown uint = 10
+	// Maximum duration to wait for a worker to stop
+	WorkerStopTimeout = 10 * time.Second
+)
+
+// Goroutine refreshing a master server until it is stopped
+type refreshWorker struct {
+	// Stops the refresh loop
+	cancel context.CancelFunc
+	// Closed when the refresh loop has returned
+	done chan struct{}
+}
+
+// Start refreshing a master server every `refreshCooldown` seconds,
+// until `ctx` is done or the worker is stopped
+func startRefreshWorker(
+	ctx context.Context,
+	masterServer masterserver.MasterServer,
+	refreshCooldown uint,
+	observer RefreshObserver,
+) *refreshWorker {
+	ctx, cancel := context.WithCancel(ctx)
+
+	w := &refreshWorker{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if refreshCooldown == 0 {
+		refreshCooldown = DefaultRefreshCooldown
+	}
+
+	go w.run(ctx, masterServer, time.Duration(refreshCooldown)*time.Second, observer)
+
+	return w
+}
+
+// Refresh loop
+func (w *refreshWorker) run(
+	ctx context.Context,
+	masterServer masterserver.MasterServer,
+	cooldown time.Duration,
+	observer RefreshObserver,
+) {
+	defer close(w.done)
+
+	metadata := masterServer.Metadata()
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		start := time.Now()
+
+		err := masterServer.RefreshWithContext(ctx)
+		if err != nil {
+			debug.Debug(
+				"could not refresh %s with protocol %s",
+				metadata.Address,
+				metadata.Protocol,
+			)
+		}
+
+		if observer != nil {
+			observe(observer, metadata, masterServer, time.Since(start), err)
+		}
+
+		t.Reset(cooldown)
+	}
+}
+
+// Observe the duration of a refresh, the phases are only
+// observed if the refresh succeeded
+func observe(
+	observer RefreshObserver,
+	metadata masterserver.MasterServerMetadata,
+	masterServer masterserver.MasterServer,
+	elapsed time.Duration,
+	err error,
+) {
+	observer(metadata, PhaseTotal, elapsed.Seconds())
+
+	if err != nil {
+		return
+	}
+
+	for phase, seconds := range masterServer.Metrics().PhasesTime {
+		observer(metadata, phase, seconds)
+	}
+}
+
+// Stop the refresh loop without waiting for it
+func (w *refreshWorker) stop() {
+	w.cancel()
+}
+
+// Wait for the refresh loop to return, false if `ctx` is done before
+func (w *refreshWorker) wait(ctx context.Context) bool {
+	select {
+	case <-w.done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,28 @@
+package udp
+
+import (
+	masterservers "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server"
+	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
+	"gopkg.in/yaml.v3"
+)
+
+// UDP master server YAML configuration
+type Config struct {
+	Host string `yaml:"host"`
+	Port uint16 `yaml:"port"`
+}
+
+func init() {
+	masterservers.RegisterProtocol(MasterServerProtocol, newFromNode)
+}
+
+// Create a UDP master server from its YAML configuration node
+func newFromNode(node *yaml.Node) (masterserver.MasterServer, error) {
+	var c Config
+
+	if err := node.Decode(&c); err != nil {
+		return nil, err
+	}
+
+	return NewMasterServer(c.Host, c.Port), nil
+}
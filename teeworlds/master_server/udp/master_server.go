@@ -1,15 +1,29 @@
 package udp
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/jxsl13/twapi/browser"
+	"github.com/theobori/teeworlds-prometheus-exporter/internal/debug"
 	"github.com/theobori/teeworlds-prometheus-exporter/teeworlds/master_server/master_server"
 	twserver "github.com/theobori/teeworlds-prometheus-exporter/teeworlds/server"
 )
 
+const (
+	// Refresh phase listing the server addresses
+	PhaseAddresses = "addresses"
+	// Refresh phase fetching the server informations
+	PhaseInfos = "infos"
+)
+
+var (
+	// Master server protocol
+	MasterServerProtocol = "udp"
+)
+
 // UDP master server controller
 type MasterServerUDP struct {
 	// Master server host
@@ -18,11 +32,13 @@ type MasterServerUDP struct {
 	port uint16
 	// Client that manage the UDP connection
 	client *browser.Client
-	// Teeworlds servers informations
-	servers []*browser.ServerInfo
+	// Teeworlds servers informations with their original format
+	serversInfo []*browser.ServerInfo
+	// Teeworlds servers informations, converted once per refresh
+	servers []*twserver.Server
 	// Master server metrics
 	metrics masterserver.MasterServerMetrics
-	// Mutex to protect `servers` and `metrics`
+	// Mutex to protect `client`, `serversInfo`, `servers` and `metrics`
 	mu sync.Mutex
 }
 
@@ -48,7 +64,7 @@ func (ms *MasterServerUDP) Address() string {
 // Get the master server metadata
 func (ms *MasterServerUDP) Metadata() masterserver.MasterServerMetadata {
 	return masterserver.MasterServerMetadata{
-		Protocol: "udp",
+		Protocol: MasterServerProtocol,
 		Address:  ms.Address(),
 	}
 }
@@ -62,6 +78,9 @@ func (ms *MasterServerUDP) Connect() error {
 		return err
 	}
 
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	ms.client = client
 
 	return nil
@@ -69,28 +88,58 @@ func (ms *MasterServerUDP) Connect() error {
 
 // Close the connection with the master server
 func (ms *MasterServerUDP) Disconnect() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	if ms.client == nil {
 		return fmt.Errorf("missing client")
 	}
 
-	return ms.client.Close()
+	err := ms.client.Close()
+	ms.client = nil
+
+	return err
+}
+
+// Get the UDP client, connecting first if needed
+func (ms *MasterServerUDP) connectedClient() (*browser.Client, error) {
+	ms.mu.Lock()
+	client := ms.client
+	ms.mu.Unlock()
+
+	if client != nil {
+		return client, nil
+	}
+
+	// The master server may have been unreachable until now
+	if err := ms.Connect(); err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.client, nil
 }
 
 // Update the teeworlds servers informations
 func (ms *MasterServerUDP) refresh() error {
-	if ms.client == nil {
-		return fmt.Errorf("missing client")
+	client, err := ms.connectedClient()
+	if err != nil {
+		return err
 	}
 
 	// Starting before we get the server addresses
 	start := time.Now()
 
 	// Get the registered teeworlds server addresses
-	addresses, err := ms.client.GetServerAddresses()
+	addresses, err := client.GetServerAddresses()
 	if err != nil {
 		return err
 	}
 
+	addressesTime := time.Since(start).Seconds()
+
 	// Get the teeworlds servers informations
 	serversInfo, err := browser.GetServerInfosOf(addresses)
 	if err != nil {
@@ -100,22 +149,51 @@ func (ms *MasterServerUDP) refresh() error {
 	// Get the elapsed time
 	elapsed := time.Since(start).Seconds()
 
+	// Converting the servers once, rather than on every scrape
+	servers := make([]*twserver.Server, 0, len(serversInfo))
+
+	for _, serverInfo := range serversInfo {
+		server, err := twserver.FromUDPFields(serverInfo)
+		if err != nil {
+			debug.Debug(err.Error())
+			continue
+		}
+
+		servers = append(servers, server)
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	ms.servers = serversInfo
-	ms.metrics.RequestTime = uint(elapsed)
+	ms.serversInfo = serversInfo
+	ms.servers = servers
+	ms.metrics.RequestTime = elapsed
+	ms.metrics.PhasesTime = map[string]float64{
+		PhaseAddresses: addressesTime,
+		PhaseInfos:     elapsed - addressesTime,
+	}
 
 	return nil
 }
 
 // Update the teeworlds servers informations
 func (ms *MasterServerUDP) Refresh() error {
+	return ms.RefreshWithContext(context.Background())
+}
+
+// Update the teeworlds servers informations, unless the context is done
+func (ms *MasterServerUDP) RefreshWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	err := ms.refresh()
 
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	ms.metrics.Up = err == nil
+
 	if err != nil {
 		ms.metrics.FailedRefreshCount++
 
@@ -123,27 +201,17 @@ func (ms *MasterServerUDP) Refresh() error {
 	}
 
 	ms.metrics.SuccessRefreshCount++
+	ms.metrics.LastSuccess = time.Now()
 
 	return nil
 }
 
 // Get the Teeworlds servers informations
 func (ms *MasterServerUDP) Servers() ([]*twserver.Server, error) {
-	var servers []*twserver.Server
-
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	for _, serverInfo := range ms.servers {
-		server, err := twserver.FromUDPFields(serverInfo)
-		if err != nil {
-			return nil, err
-		}
-
-		servers = append(servers, server)
-	}
-
-	return servers, nil
+	return ms.servers, nil
 }
 
 // Get the Teeworlds servers informations with its original format
@@ -151,7 +219,7 @@ func (ms *MasterServerUDP) ServersInfo() []*browser.ServerInfo {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	return ms.servers
+	return ms.serversInfo
 }
 
 // Get the master server metrics
@@ -161,3 +229,17 @@ func (ms *MasterServerUDP) Metrics() masterserver.MasterServerMetrics {
 
 	return ms.metrics
 }
+
+// Get the servers and the metrics at once
+func (ms *MasterServerUDP) Snapshot() masterserver.MasterServerSnapshot {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return masterserver.MasterServerSnapshot{
+		Metadata:     ms.Metadata(),
+		Servers:      ms.servers,
+		TotalServers: len(ms.servers),
+		TotalPlayers: masterserver.CountPlayers(ms.servers),
+		Metrics:      ms.metrics,
+	}
+}
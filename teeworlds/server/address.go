@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Teeworlds 0.6 network protocol scheme, also used by DDNet
+	SchemeTeeworlds06 = "tw-0.6+udp"
+	// Teeworlds 0.7 network protocol scheme
+	SchemeTeeworlds07 = "tw-0.7+udp"
+
+	// Separator between the scheme and the host
+	schemeSeparator = "://"
+)
+
+// Teeworlds server address, like the DDNet HTTP master servers ones
+// (e.g `tw-0.6+udp://1.2.3.4:8303` or `tw-0.7+udp://[::1]:8303`)
+type Address struct {
+	// Network protocol scheme, empty if unknown
+	Scheme string
+	// IP address or hostname
+	Host string
+	// UDP port
+	Port uint16
+}
+
+// Parse a server address, the scheme is optional
+func ParseAddress(s string) (Address, error) {
+	var address Address
+
+	if scheme, rest, found := strings.Cut(s, schemeSeparator); found {
+		address.Scheme = strings.ToLower(scheme)
+		s = rest
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return Address{}, err
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid port %q", port)
+	}
+
+	if host == "" {
+		return Address{}, fmt.Errorf("missing host in %q", s)
+	}
+
+	address.Port = uint16(p)
+
+	// Writing the IP addresses in their canonical form
+	if ip, err := netip.ParseAddr(host); err == nil {
+		address.Host = ip.Unmap().String()
+	} else {
+		address.Host = strings.ToLower(host)
+	}
+
+	return address, nil
+}
+
+// Get the IP address, invalid if the host is a hostname
+func (a Address) IP() netip.Addr {
+	ip, err := netip.ParseAddr(a.Host)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return ip
+}
+
+// Get the Teeworlds network protocol (e.g `0.6`), empty if unknown
+func (a Address) NetProtocol() string {
+	protocol := strings.TrimPrefix(a.Scheme, "tw-")
+	protocol, _, _ = strings.Cut(protocol, "+")
+
+	return protocol
+}
+
+// Get the network address, without the scheme
+func (a Address) HostPort() string {
+	return net.JoinHostPort(a.Host, strconv.FormatUint(uint64(a.Port), 10))
+}
+
+// Check if two addresses target the same server, whatever their scheme
+func (a Address) SameHostPort(other Address) bool {
+	return a.Host == other.Host && a.Port == other.Port
+}
+
+// Get the address with its scheme
+func (a Address) String() string {
+	if a.Scheme == "" {
+		return a.HostPort()
+	}
+
+	return a.Scheme + schemeSeparator + a.HostPort()
+}
+
+// Get the network address of a server address, without the scheme used by
+// the HTTP master servers, so the same server is identified whatever its
+// source. An unparsable address is only lower cased.
+func NormalizeAddress(address string) string {
+	a, err := ParseAddress(address)
+	if err != nil {
+		return strings.ToLower(address)
+	}
+
+	return a.HostPort()
+}
+
+// Get the parsed addresses of a server, skipping the invalid ones
+func (s *Server) ParsedAddresses() []Address {
+	var addresses []Address
+
+	for _, address := range s.Addresses {
+		a, err := ParseAddress(address)
+		if err != nil {
+			continue
+		}
+
+		addresses = append(addresses, a)
+	}
+
+	return addresses
+}
@@ -0,0 +1,56 @@
+package server
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address     string
+		expected    Address
+		netProtocol string
+		hostPort    string
+	}{
+		{
+			"tw-0.6+udp://1.2.3.4:8303",
+			Address{Scheme: SchemeTeeworlds06, Host: "1.2.3.4", Port: 8303},
+			"0.6",
+			"1.2.3.4:8303",
+		},
+		{
+			"tw-0.7+udp://[2001:DB8:0::1]:8304",
+			Address{Scheme: SchemeTeeworlds07, Host: "2001:db8::1", Port: 8304},
+			"0.7",
+			"[2001:db8::1]:8304",
+		},
+		{
+			"Localhost:8303",
+			Address{Host: "localhost", Port: 8303},
+			"",
+			"localhost:8303",
+		},
+	}
+
+	for _, test := range tests {
+		address, err := ParseAddress(test.address)
+		if err != nil {
+			t.Fatalf("%s: %v", test.address, err)
+		}
+
+		if address != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.address, test.expected, address)
+		}
+
+		if address.NetProtocol() != test.netProtocol {
+			t.Errorf("%s: expected net protocol %q, got %q", test.address, test.netProtocol, address.NetProtocol())
+		}
+
+		if address.HostPort() != test.hostPort {
+			t.Errorf("%s: expected %q, got %q", test.address, test.hostPort, address.HostPort())
+		}
+	}
+
+	for _, invalid := range []string{"", "1.2.3.4", "tw-0.6+udp://1.2.3.4:port", "1.2.3.4:70000", ":8303"} {
+		if _, err := ParseAddress(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
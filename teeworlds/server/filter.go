@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Server filter criteria, a criterion without any value is ignored
+type FilterRule struct {
+	// Game types, case insensitive
+	GameTypes []string
+	// Server name regexes
+	Names []*regexp.Regexp
+	// Server version regexes
+	Versions []*regexp.Regexp
+	// Communities, as listed by the DDNet HTTP master servers
+	Communities []string
+	// Address ranges
+	Networks []netip.Prefix
+}
+
+// Check if a server matches the game types criterion
+func (r *FilterRule) matchGameType(s *Server) bool {
+	return slices.ContainsFunc(r.GameTypes, func(gameType string) bool {
+		return strings.EqualFold(gameType, s.Info.GameType)
+	})
+}
+
+// Check if a server matches the names criterion
+func (r *FilterRule) matchName(s *Server) bool {
+	return slices.ContainsFunc(r.Names, func(re *regexp.Regexp) bool {
+		return re.MatchString(s.Info.Name)
+	})
+}
+
+// Check if a server matches the versions criterion
+func (r *FilterRule) matchVersion(s *Server) bool {
+	return slices.ContainsFunc(r.Versions, func(re *regexp.Regexp) bool {
+		return re.MatchString(s.Info.Version)
+	})
+}
+
+// Check if a server matches the communities criterion
+func (r *FilterRule) matchCommunity(s *Server) bool {
+	return slices.Contains(r.Communities, s.Community)
+}
+
+// Check if one of the server addresses matches the networks criterion
+func (r *FilterRule) matchNetwork(s *Server) bool {
+	for _, address := range s.ParsedAddresses() {
+		ip := address.IP()
+		if !ip.IsValid() {
+			continue
+		}
+
+		for _, network := range r.Networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Get the result of every criterion with at least one value
+func (r *FilterRule) results(s *Server) []bool {
+	var results []bool
+
+	if len(r.GameTypes) > 0 {
+		results = append(results, r.matchGameType(s))
+	}
+
+	if len(r.Names) > 0 {
+		results = append(results, r.matchName(s))
+	}
+
+	if len(r.Versions) > 0 {
+		results = append(results, r.matchVersion(s))
+	}
+
+	if len(r.Communities) > 0 {
+		results = append(results, r.matchCommunity(s))
+	}
+
+	if len(r.Networks) > 0 {
+		results = append(results, r.matchNetwork(s))
+	}
+
+	return results
+}
+
+// Include and exclude rules deciding which servers are exported
+type Filter struct {
+	// A server must match every criterion, nil includes every server
+	Include *FilterRule
+	// A server matching any criterion is excluded, nil excludes nothing
+	Exclude *FilterRule
+}
+
+// Check if a server passes the filter, a nil filter lets every server pass
+func (f *Filter) Match(s *Server) bool {
+	if f == nil || s == nil {
+		return true
+	}
+
+	if f.Include != nil && slices.Contains(f.Include.results(s), false) {
+		return false
+	}
+
+	if f.Exclude != nil && slices.Contains(f.Exclude.results(s), true) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/netip"
+	"regexp"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	filter := &Filter{
+		Include: &FilterRule{
+			GameTypes: []string{"ctf"},
+			Networks:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		},
+		Exclude: &FilterRule{
+			Names: []*regexp.Regexp{regexp.MustCompile(`(?i)test`)},
+		},
+	}
+
+	tests := []struct {
+		server   Server
+		expected bool
+	}{
+		{Server{Addresses: []string{"tw-0.7+udp://10.1.2.3:8303"}, Info: ServerInfo{GameType: "CTF", Name: "ctf"}}, true},
+		{Server{Addresses: []string{"tw-0.7+udp://10.1.2.3:8303"}, Info: ServerInfo{GameType: "CTF", Name: "Test server"}}, false},
+		{Server{Addresses: []string{"tw-0.7+udp://11.1.2.3:8303"}, Info: ServerInfo{GameType: "CTF", Name: "ctf"}}, false},
+		{Server{Addresses: []string{"tw-0.7+udp://10.1.2.3:8303"}, Info: ServerInfo{GameType: "DM", Name: "dm"}}, false},
+	}
+
+	for _, test := range tests {
+		if got := filter.Match(&test.server); got != test.expected {
+			t.Errorf("%+v: expected %v, got %v", test.server, test.expected, got)
+		}
+	}
+
+	var nilFilter *Filter
+
+	if !nilFilter.Match(&tests[3].server) {
+		t.Error("a nil filter must let every server pass")
+	}
+}
@@ -14,6 +14,7 @@ type Servers struct {
 type Server struct {
 	Addresses []string   `json:"addresses"`
 	Location  string     `json:"location"`
+	Community string     `json:"community"`
 	Info      ServerInfo `json:"info"`
 }
 
@@ -27,6 +28,12 @@ type ServerInfo struct {
 	Version         string            `json:"version"`
 	ClientScoreKind string            `json:"client_score_kind"`
 	Clients         []twclient.Client `json:"clients"`
+	// Whether the clients AFK state is known, it is only
+	// sent by the HTTP master servers
+	AfkKnown bool `json:"-"`
+	// Whether the clients bot flag is known, it is only
+	// sent by the Teeworlds 0.7 servers
+	BotsKnown bool `json:"-"`
 }
 
 type ServerMap struct {
@@ -76,10 +83,12 @@ func FromUDPFields(other *browser.ServerInfo) (*Server, error) {
 		Map:        m,
 		Version:    other.Version,
 		Clients:    clients,
+		BotsKnown:  true,
 	}
 
 	server.Info = serverInfo
-	server.Addresses = []string{other.Address}
+	// The UDP master servers and game servers speak the 0.7 protocol
+	server.Addresses = []string{SchemeTeeworlds07 + "://" + other.Address}
 	server.Location = ""
 
 	return &server, nil